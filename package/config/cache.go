@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+// CacheConfig holds the in process cache, used alone or in front of Redis
+type CacheConfig struct {
+	Local LocalCacheConfig `mapstructure:"local"`
+}
+
+type LocalCacheConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MaxEntries bounds the number of keys, the least recently used are evicted first. 0 is unbounded.
+	MaxEntries int `mapstructure:"max_entries"`
+	// MaxBytes bounds the size of the values, 0 is unbounded
+	MaxBytes int64 `mapstructure:"max_bytes"`
+	// TTL bounds how long the values read from Redis are kept in process, 0 keeps them for their Redis TTL
+	TTL time.Duration `mapstructure:"ttl"`
+	// CleanupInterval is the period of the removal of the expired keys
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+	// InvalidationTopic is the NATS topic the instances evict the keys changed by the others on
+	InvalidationTopic string `mapstructure:"invalidation_topic"`
+}
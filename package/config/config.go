@@ -13,6 +13,7 @@ type Config[T any] struct {
 	Servers   ServerConfig    `mapstructure:"servers"`
 	Database  DatabaseConfig  `mapstructure:"database"`
 	Redis     RedisConfig     `mapstructure:"redis"`
+	Cache     CacheConfig     `mapstructure:"cache"`
 	NATS      NATSConfig      `mapstructure:"nats"`
 	ETCD      ETCDConfig      `mapstructure:"etcd"`
 	JWT       JWTConfig       `mapstructure:"jwt"`
@@ -19,6 +19,35 @@ type DatabaseConfig struct {
 	MinIdleConns    int           `mapstructure:"min_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
 	MigrationsPath  string        `mapstructure:"migrations_path"`
+	// EntityEvents publishes federation-ready events for the mutations of the listed ent types
+	EntityEvents []EntityEventConfig `mapstructure:"entity_events"`
+	// Outbox stores the entity change events in the mutation transaction, a relay publishes them
+	Outbox OutboxConfig `mapstructure:"outbox"`
+}
+
+// OutboxConfig configures the outbox relay. The relay runs as a scheduled queue task when
+// the queue and the scheduler are enabled, in a goroutine of each replica otherwise.
+type OutboxConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Interval between two relays, 5s by default
+	Interval time.Duration `mapstructure:"interval"`
+	// BatchSize is the number of events published by a relay, 100 by default
+	BatchSize int `mapstructure:"batch_size"`
+	// MaxAttempts before an event is left aside for investigation, 10 by default
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Retention of the published events, 24h by default
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+// EntityEventConfig maps an ent type to its GraphQL entity and to the subjects of its events
+type EntityEventConfig struct {
+	// Entity is the ent type, e.g. User
+	Entity string `mapstructure:"entity"`
+	// TypeName is the __typename of the events, e.g. UserEntity
+	TypeName string `mapstructure:"type_name"`
+	// Subjects maps an action (created, updated, deleted, soft_deleted) to a subject template,
+	// {id} and the mutated fields, e.g. {name}, are replaced by their value
+	Subjects map[string]string `mapstructure:"subjects"`
 }
 
 func (c *DatabaseConfig) GetURL() string {
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultEventProviderID is the provider used by the @edfs directives without providerId
+const DefaultEventProviderID = "default"
+
+const (
+	EventProviderTypeNats      = "nats"
+	EventProviderTypeJetStream = "jetstream"
+)
+
+const (
+	// EventEncodingMsgPack frames the payloads like the psnats clients of the services
+	EventEncodingMsgPack = "msgpack"
+	// EventEncodingJSON exchanges the JSON payloads as they are, declared by the content-type header
+	EventEncodingJSON = "json"
+)
+
+// EventsConfig holds the EDFS event providers of the federation gateway
+type EventsConfig struct {
+	Providers []EventProviderConfig `mapstructure:"providers"`
+}
+
+// EventProviderConfig declares a named provider, referenced by the providerId argument of the @edfs directives
+type EventProviderConfig struct {
+	ID string `mapstructure:"id"`
+	// Type is nats or jetstream, only jetstream providers accept subscriptions with a stream configuration
+	Type string `mapstructure:"type"`
+	URL  string `mapstructure:"url"`
+	// BasePath prefixes the subjects of the schema, the same way NATSConfig.BasePath does for the services
+	BasePath string `mapstructure:"base_path"`
+	// Encoding of the payloads, msgpack when empty so that the services can answer without HTTP
+	Encoding string `mapstructure:"encoding"`
+	// ClientName is the connection name and the "from" header of the published messages
+	ClientName     string                  `mapstructure:"client_name"`
+	Authentication EventProviderAuthConfig `mapstructure:"authentication"`
+	TLS            EventProviderTLSConfig  `mapstructure:"tls"`
+}
+
+// The event providers connect like the NATS clients of the services
+type (
+	EventProviderAuthConfig = NATSAuthConfig
+	EventProviderTLSConfig  = NATSTLSConfig
+)
+
+func (p EventProviderConfig) GetID() string {
+	return p.ID
+}
+
+func (p EventProviderConfig) GetEncoding() string {
+	if p.Encoding == "" {
+		return EventEncodingMsgPack
+	}
+	return p.Encoding
+}
+
+// Validate checks the providers declaration, the providers used by the schema are checked when it is composed
+func (c EventsConfig) Validate() error {
+	var errs []error
+
+	ids := make(map[string]struct{}, len(c.Providers))
+	for i, provider := range c.Providers {
+		if provider.ID == "" {
+			errs = append(errs, fmt.Errorf("event provider %d: id is required", i))
+			continue
+		}
+		if _, ok := ids[provider.ID]; ok {
+			errs = append(errs, fmt.Errorf("event provider %s: duplicated id", provider.ID))
+		}
+		ids[provider.ID] = struct{}{}
+
+		if provider.URL == "" {
+			errs = append(errs, fmt.Errorf("event provider %s: url is required", provider.ID))
+		}
+
+		switch provider.Type {
+		case "", EventProviderTypeNats, EventProviderTypeJetStream:
+		default:
+			errs = append(errs, fmt.Errorf("event provider %s: unknown type %q", provider.ID, provider.Type))
+		}
+
+		switch provider.Encoding {
+		case "", EventEncodingMsgPack, EventEncodingJSON:
+		default:
+			errs = append(errs, fmt.Errorf("event provider %s: unknown encoding %q", provider.ID, provider.Encoding))
+		}
+
+		if (provider.TLS.CertFile == "") != (provider.TLS.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("event provider %s: tls cert_file and key_file must be set together", provider.ID))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -1,14 +1,89 @@
 package config
 
-import "time"
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// NATSDriverNATS connects to the NATS server of the endpoint
+	NATSDriverNATS = "nats"
+	// NATSDriverMemory exchanges the messages in process, for the tests and the single binary mode
+	NATSDriverMemory = "memory"
+)
 
 // NATSConfig holds NATS configuration
 type NATSConfig struct {
-	Enabled        bool          `mapstructure:"enabled" yaml:"enabled" envDefault:"false"`
-	Name           string        `mapstructure:"name" yaml:"name"`
-	Endpoint       string        `mapstructure:"endpoint" yaml:"endpoint" envDefault:"nats://127.0.0.1:4222"`
+	Enabled  bool   `mapstructure:"enabled" yaml:"enabled" envDefault:"false"`
+	Driver   string `mapstructure:"driver" yaml:"driver" envDefault:"nats"`
+	Name     string `mapstructure:"name" yaml:"name"`
+	Endpoint string `mapstructure:"endpoint" yaml:"endpoint" envDefault:"nats://127.0.0.1:4222"`
+	// Endpoints are the seed servers of the cluster, Endpoint is used when empty
+	Endpoints      []string      `mapstructure:"endpoints" yaml:"endpoints"`
 	BasePath       string        `mapstructure:"base_path" yaml:"base_path" envDefault:"local"`
 	AllowReconnect bool          `mapstructure:"allow_reconnect" yaml:"allow_reconnect" envDefault:"true"`
 	MaxReconnects  int           `mapstructure:"max_reconnects" yaml:"max_reconnects" envDefault:"500"`
 	PingInterval   time.Duration `mapstructure:"ping_interval" yaml:"ping_interval" envDefault:"10s"`
+	// ConnectAttempts bounds the attempts of the first connection, 10 when 0, a negative value retries forever
+	ConnectAttempts int `mapstructure:"connect_attempts" yaml:"connect_attempts" envDefault:"10"`
+	// ConnectBackoff is the delay after the first failed attempt, doubled after each of the next ones
+	ConnectBackoff time.Duration `mapstructure:"connect_backoff" yaml:"connect_backoff" envDefault:"1s"`
+	// DrainTimeout bounds the time the handlers have to finish their messages on shutdown
+	DrainTimeout time.Duration `mapstructure:"drain_timeout" yaml:"drain_timeout" envDefault:"30s"`
+	// ContentType of the published messages: msgpack or json. The consumers decode by the message headers,
+	// protobuf included.
+	ContentType string `mapstructure:"content_type" yaml:"content_type" envDefault:"msgpack"`
+	// ContentEncoding compresses the published messages: gzip or zstd, none when empty
+	ContentEncoding string `mapstructure:"content_encoding" yaml:"content_encoding"`
+
+	Authentication NATSAuthConfig `mapstructure:"authentication" yaml:"authentication"`
+	TLS            NATSTLSConfig  `mapstructure:"tls" yaml:"tls"`
+
+	JetStream NATSJetStreamConfig `mapstructure:"jetstream" yaml:"jetstream"`
+}
+
+// NATSAuthConfig holds the credentials of a NATS connection, the first one set is used:
+// credentials file (JWT and NKey), NKey seed file, token, then user and password
+type NATSAuthConfig struct {
+	Username        string `mapstructure:"username" yaml:"username"`
+	Password        string `mapstructure:"password" yaml:"password"`
+	Token           string `mapstructure:"token" yaml:"token"`
+	CredentialsFile string `mapstructure:"credentials_file" yaml:"credentials_file"`
+	NKeyFile        string `mapstructure:"nkey_file" yaml:"nkey_file"`
+}
+
+type NATSTLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled" yaml:"enabled"`
+	CAFile             string `mapstructure:"ca_file" yaml:"ca_file"`
+	CertFile           string `mapstructure:"cert_file" yaml:"cert_file"`
+	KeyFile            string `mapstructure:"key_file" yaml:"key_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+}
+
+// URLs returns the servers to connect to, comma separated as nats.Connect expects them
+func (c NATSConfig) URLs() string {
+	if len(c.Endpoints) == 0 {
+		return c.Endpoint
+	}
+	return strings.Join(c.Endpoints, ",")
+}
+
+// NATSJetStreamConfig opts the subscriptions of the subjects in JetStream consumption,
+// with acknowledgements, redeliveries and a dead-letter subject
+type NATSJetStreamConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled" envDefault:"false"`
+	// Stream captures the subjects, it is created or updated to this config on startup
+	Stream string `mapstructure:"stream" yaml:"stream"`
+	// Subjects are the topics, without base path, consumed from the stream. Wildcards are allowed.
+	Subjects   []string        `mapstructure:"subjects" yaml:"subjects"`
+	AckWait    time.Duration   `mapstructure:"ack_wait" yaml:"ack_wait" envDefault:"30s"`
+	MaxDeliver int             `mapstructure:"max_deliver" yaml:"max_deliver" envDefault:"5"`
+	Backoff    []time.Duration `mapstructure:"backoff" yaml:"backoff"`
+	// DeadLetterSubject prefixes the subjects of the messages exceeding MaxDeliver, e.g. "dlq.account.user.created"
+	DeadLetterSubject string `mapstructure:"dead_letter_subject" yaml:"dead_letter_subject" envDefault:"dlq"`
+}
+
+// DeadLetterStream is the stream of the dead letters, kept apart so that replaying them does not loop
+func (c NATSJetStreamConfig) DeadLetterStream() string {
+	return c.Stream + "_DLQ"
 }
@@ -1,11 +1,86 @@
 package config
 
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// RedisModeSingle connects to the server of Host and Port
+	RedisModeSingle = "single"
+	// RedisModeSentinel connects to the master named MasterName, discovered through the sentinels of Addrs
+	RedisModeSentinel = "sentinel"
+	// RedisModeCluster connects to the cluster of the seed nodes of Addrs
+	RedisModeCluster = "cluster"
+)
+
 // RedisConfig holds Redis configuration
 type RedisConfig struct {
-	Enabled  bool   `mapstructure:"enabled"`
+	Enabled bool `mapstructure:"enabled"`
+	// Mode is single, sentinel or cluster, single when empty
+	Mode     string `mapstructure:"mode"`
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
 	Database int    `mapstructure:"database"`
 	PoolSize int    `mapstructure:"pool_size"`
+
+	// Addrs are the sentinels, or the seed nodes of the cluster
+	Addrs []string `mapstructure:"addrs"`
+	// MasterName is the master monitored by the sentinels
+	MasterName       string `mapstructure:"master_name"`
+	SentinelUsername string `mapstructure:"sentinel_username"`
+	SentinelPassword string `mapstructure:"sentinel_password"`
+
+	// ConnectAttempts bounds the attempts at startup, the app then starts without Redis and keeps reconnecting
+	ConnectAttempts int `mapstructure:"connect_attempts"`
+	// ConnectBackoff is the delay after the first failed attempt, doubled after each of the next ones
+	ConnectBackoff time.Duration `mapstructure:"connect_backoff"`
+
+	TLS RedisTLSConfig `mapstructure:"tls"`
+}
+
+type RedisTLSConfig = NATSTLSConfig
+
+// Addr returns the address of the single server
+func (c RedisConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// Endpoints returns the addresses to connect to: the sentinels or the seed nodes, Addr when Addrs is empty
+func (c RedisConfig) Endpoints() []string {
+	if c.Mode == RedisModeSingle || c.Mode == "" || len(c.Addrs) == 0 {
+		return []string{c.Addr()}
+	}
+	return c.Addrs
+}
+
+// Validate reports the settings the mode misses
+func (c RedisConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Mode {
+	case "", RedisModeSingle:
+	case RedisModeSentinel:
+		if c.MasterName == "" {
+			return fmt.Errorf("redis sentinel mode requires master_name")
+		}
+		if len(c.Addrs) == 0 {
+			return fmt.Errorf("redis sentinel mode requires the sentinel addrs")
+		}
+	case RedisModeCluster:
+		if c.Database != 0 {
+			return fmt.Errorf("redis cluster mode only has the database 0")
+		}
+	default:
+		return fmt.Errorf("unknown redis mode %q, expected single, sentinel or cluster", c.Mode)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("redis tls cert_file and key_file must be set together")
+	}
+	return nil
 }
@@ -1,5 +1,11 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
 	HTTP       HTTPConfig       `mapstructure:"http"`
@@ -26,6 +32,83 @@ type FederationConfig struct {
 	Playground bool             `mapstructure:"playground"`
 	Subgraphs  []SubgraphConfig `mapstructure:"subgraphs" json:"subgraphs"`
 	Complexity ComplexityConfig `mapstructure:"complexity"`
+	PlanCache  PlanCacheConfig  `mapstructure:"plan_cache"`
+	Errors     ErrorsConfig     `mapstructure:"errors"`
+	Contracts  ContractsConfig  `mapstructure:"contracts"`
+	Events     EventsConfig     `mapstructure:"events"`
+}
+
+// ContractsConfig holds the variants of the client schema exposed by the federation gateway.
+// The variants are bound to routes, the clients cannot select another variant than the one of their route.
+type ContractsConfig struct {
+	// Default is the variant served on /graphql and /ws, empty serves the full schema
+	Default  string           `mapstructure:"default"`
+	Variants []ContractConfig `mapstructure:"variants"`
+}
+
+// gatewayRoutes are served by the federation gateway itself, the contract routes cannot take them
+var gatewayRoutes = []string{"/graphql", "/ws", "/playground"}
+
+// Validate checks that the variants have unique names and routes, apart from the gateway ones,
+// and that the default variant is declared
+func (c ContractsConfig) Validate() error {
+	names := make(map[string]struct{}, len(c.Variants))
+	routes := make(map[string]string, len(gatewayRoutes)+2*len(c.Variants))
+	for _, route := range gatewayRoutes {
+		routes[route] = ""
+	}
+
+	for i, variant := range c.Variants {
+		if variant.Name == "" {
+			return fmt.Errorf("contract %d: name is required", i)
+		}
+		if _, ok := names[variant.Name]; ok {
+			return fmt.Errorf("contract %q is declared twice", variant.Name)
+		}
+		names[variant.Name] = struct{}{}
+
+		if variant.Route == "" {
+			continue
+		}
+		// the variant is served over websocket on <route>/ws as well. Fiber routes ignore the case
+		// and the trailing slash by default.
+		route := strings.ToLower(strings.TrimSuffix(variant.Route, "/"))
+		for _, route := range []string{route, route + "/ws"} {
+			if other, ok := routes[route]; ok {
+				if other == "" {
+					return fmt.Errorf("contract %q: route %s is served by the gateway", variant.Name, route)
+				}
+				return fmt.Errorf("contract %q: route %s is served by contract %q", variant.Name, route, other)
+			}
+			routes[route] = variant.Name
+		}
+	}
+
+	if _, ok := names[c.Default]; c.Default != "" && !ok {
+		return fmt.Errorf("default contract %q is not declared", c.Default)
+	}
+	return nil
+}
+
+// ContractConfig describes a client schema variant built from the @tag directives of the subgraphs
+type ContractConfig struct {
+	Name string `mapstructure:"name"`
+	// Route serves the variant on its own path, and over websocket on <route>/ws
+	Route string `mapstructure:"route"`
+	// ExcludeTags hides the types and fields tagged with one of these names
+	ExcludeTags []string `mapstructure:"exclude_tags"`
+}
+
+// PlanCacheConfig holds the execution plan cache configuration of the federation gateway
+type PlanCacheConfig struct {
+	// Size is the maximum number of execution plans kept in memory
+	Size int `mapstructure:"size"`
+	// WarmupSize is the number of most used operations replanned after a schema update
+	WarmupSize int `mapstructure:"warmup_size"`
+	// Persist stores the normalized operations, their literals extracted as variables, in Redis
+	// so new replicas can warm their cache on startup
+	Persist    bool          `mapstructure:"persist"`
+	PersistTTL time.Duration `mapstructure:"persist_ttl"`
 }
 
 type SubgraphConfig struct {
@@ -36,6 +119,37 @@ type SubgraphConfig struct {
 	Retries int               `mapstructure:"retries" json:"retries"`
 }
 
+const (
+	ErrorsModeWrapped     = "wrapped"
+	ErrorsModePassthrough = "pass-through"
+)
+
+// ErrorsConfig holds the policy applied to errors returned by subgraphs
+type ErrorsConfig struct {
+	// Mode is either "wrapped" or "pass-through", the default
+	Mode                    string   `mapstructure:"mode"`
+	AllowedExtensionFields  []string `mapstructure:"allowed_extension_fields"`
+	AllowAllExtensionFields bool     `mapstructure:"allow_all_extension_fields"`
+	AttachServiceName       bool     `mapstructure:"attach_service_name"`
+	DefaultExtensionCode    string   `mapstructure:"default_extension_code"`
+	PropagateStatusCodes    bool     `mapstructure:"propagate_status_codes"`
+	OmitLocations           bool     `mapstructure:"omit_locations"`
+	// MaskInternalErrors replaces the message of internal errors, it is always enabled in production
+	MaskInternalErrors bool     `mapstructure:"mask_internal_errors"`
+	MaskedMessage      string   `mapstructure:"masked_message"`
+	InternalErrorCodes []string `mapstructure:"internal_error_codes"`
+}
+
+// Validate rejects an unknown mode rather than silently passing the subgraph errors through
+func (c ErrorsConfig) Validate() error {
+	switch c.Mode {
+	case "", ErrorsModeWrapped, ErrorsModePassthrough:
+		return nil
+	default:
+		return fmt.Errorf("errors mode %q: must be %q or %q", c.Mode, ErrorsModeWrapped, ErrorsModePassthrough)
+	}
+}
+
 type ComplexityConfig struct {
 	Enabled bool `yaml:"enabled,omitempty"`
 	Limit   int  `yaml:"limit,omitempty"`
@@ -2,9 +2,20 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+var (
+	// ErrLockNotAcquired is returned by Lock when another holder kept the key until the context ended
+	ErrLockNotAcquired = errors.New("cache: lock not acquired")
+	// ErrLockNotHeld is returned by Unlock and Refresh once the lock expired
+	ErrLockNotHeld = errors.New("cache: lock not held")
+	// ErrLockTTL is returned by Lock and Refresh for a ttl which is not positive, the locks always expire
+	ErrLockTTL = errors.New("cache: lock ttl must be positive")
+)
+
+// Cache stores bytes by key. Get returns an empty value, and no error, for the missing keys.
 type Cache interface {
 	// Basic operations
 	Get(ctx context.Context, key string) ([]byte, error)
@@ -25,8 +36,10 @@ type Cache interface {
 	Keys(ctx context.Context, pattern string) ([]string, error)
 	Scan(ctx context.Context, pattern string) Iterator
 
-	// Lock operations
-	Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+	// Lock operations. Lock blocks: it waits for the key until the context ends, then fails with
+	// ErrLockNotAcquired, so bound the context of the callers which used to give up at once.
+	// The caches also have a TryLock making a single attempt.
+	Lock(ctx context.Context, key string, ttl time.Duration) (FencedLock, error)
 
 	// Health
 	Ping(ctx context.Context) error
@@ -44,3 +57,24 @@ type Lock interface {
 	Unlock(ctx context.Context) error
 	Refresh(ctx context.Context, ttl time.Duration) error
 }
+
+// FencedLock is returned by the Lock of the caches
+type FencedLock interface {
+	Lock
+	// Token increases with every acquisition of the key, pass it to the writes the lock protects
+	// so that the storage rejects the writes of a holder which lost the lock
+	Token() int64
+	// Lost is closed when the lock expired, or was taken by another holder, before being unlocked
+	Lost() <-chan struct{}
+}
+
+// ScanKeys returns the keys matching the pattern, iterating with Scan rather than blocking the cache with Keys
+func ScanKeys(ctx context.Context, c Cache, pattern string) ([]string, error) {
+	var keys []string
+
+	it := c.Scan(ctx, pattern)
+	for it.Next(ctx) {
+		keys = append(keys, it.Key())
+	}
+	return keys, it.Error()
+}
@@ -0,0 +1,36 @@
+package cache
+
+import "strings"
+
+const keySeparator = ":"
+
+// Keys builds the keys of a namespace, e.g. "account:user:42", so that the services sharing a Redis do not collide
+type Keys struct {
+	prefix string
+}
+
+// NewKeys returns the key builder of the namespace, usually the service name followed by the entity
+func NewKeys(namespace ...string) Keys {
+	return Keys{prefix: strings.Join(namespace, keySeparator)}
+}
+
+// Key joins the parts to the namespace
+func (k Keys) Key(parts ...string) string {
+	if len(parts) == 0 {
+		return k.prefix
+	}
+	if k.prefix == "" {
+		return strings.Join(parts, keySeparator)
+	}
+	return k.prefix + keySeparator + strings.Join(parts, keySeparator)
+}
+
+// Pattern matches the keys of the namespace under the parts, for Keys and Scan
+func (k Keys) Pattern(parts ...string) string {
+	return k.Key(append(parts, "*")...)
+}
+
+// Sub returns the builder of a nested namespace
+func (k Keys) Sub(parts ...string) Keys {
+	return Keys{prefix: k.Key(parts...)}
+}
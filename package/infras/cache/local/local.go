@@ -0,0 +1,559 @@
+package clocal
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gianglt2198/federation-go/package/infras/cache"
+	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+const defaultCleanupInterval = time.Minute
+
+var ErrClosed = errors.New("local cache closed")
+
+type (
+	// Local is an in process cache.Cache: the keys expire with their TTL, the least recently used are evicted
+	// past MaxEntries or MaxBytes, and the locks only exclude the holders of the same process.
+	Local struct {
+		maxEntries int
+		maxBytes   int64
+
+		entries map[string]*list.Element
+		lru     *list.List
+		size    int64
+		locks   map[string]*localLock
+		// fence numbers the locks of every key, so that the tokens of each key increase
+		fence int64
+		// tags are the keys of each tag, pruned of the removed keys with the expired ones
+		tags map[string]map[string]struct{}
+
+		onEvict func(key string)
+
+		closed chan struct{}
+		once   sync.Once
+		mu     sync.Mutex
+	}
+
+	entry struct {
+		key       string
+		value     []byte
+		expiresAt time.Time
+	}
+
+	Option func(*Local)
+)
+
+var (
+	_ cache.Cache  = (*Local)(nil)
+	_ cache.Tagger = (*Local)(nil)
+)
+
+// WithMaxEntries bounds the number of keys, 0 is unbounded
+func WithMaxEntries(n int) Option {
+	return func(l *Local) {
+		l.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the size of the values, 0 is unbounded
+func WithMaxBytes(n int64) Option {
+	return func(l *Local) {
+		l.maxBytes = n
+	}
+}
+
+// WithEvictionHandler is called, outside of the cache lock, with the keys evicted to respect the bounds
+func WithEvictionHandler(fn func(key string)) Option {
+	return func(l *Local) {
+		l.onEvict = fn
+	}
+}
+
+// New returns a local cache removing the expired keys every cleanupInterval, a minute when 0
+func New(cleanupInterval time.Duration, opts ...Option) *Local {
+	l := &Local{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		locks:   make(map[string]*localLock),
+		tags:    make(map[string]map[string]struct{}),
+		closed:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	if cleanupInterval <= 0 {
+		cleanupInterval = defaultCleanupInterval
+	}
+	go l.janitor(cleanupInterval)
+
+	return l
+}
+
+func (l *Local) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.DeleteExpired()
+		case <-l.closed:
+			return
+		}
+	}
+}
+
+// DeleteExpired removes the expired keys, the reads skip them already
+func (l *Local) DeleteExpired() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, elem := range l.entries {
+		if elem.Value.(*entry).expired(now) {
+			l.remove(elem)
+		}
+	}
+	for key, lock := range l.locks {
+		if lock.expired(now) {
+			delete(l.locks, key)
+		}
+	}
+	for tag, keys := range l.tags {
+		for key := range keys {
+			if _, ok := l.entries[key]; !ok {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(l.tags, tag)
+		}
+	}
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// lookup returns the live entry of the key, and marks it as recently used
+func (l *Local) lookup(key string) *entry {
+	elem, ok := l.entries[key]
+	if !ok {
+		return nil
+	}
+
+	e := elem.Value.(*entry)
+	if e.expired(time.Now()) {
+		l.remove(elem)
+		return nil
+	}
+
+	l.lru.MoveToFront(elem)
+	return e
+}
+
+func (l *Local) remove(elem *list.Element) {
+	e := l.lru.Remove(elem).(*entry)
+	delete(l.entries, e.key)
+	l.size -= int64(len(e.value))
+}
+
+func (l *Local) set(key string, value []byte, ttl time.Duration) []string {
+	value = append([]byte(nil), value...)
+
+	if elem, ok := l.entries[key]; ok {
+		e := elem.Value.(*entry)
+		l.size += int64(len(value) - len(e.value))
+		e.value = value
+		e.expiresAt = expiresAt(ttl)
+		l.lru.MoveToFront(elem)
+	} else {
+		l.entries[key] = l.lru.PushFront(&entry{key: key, value: value, expiresAt: expiresAt(ttl)})
+		l.size += int64(len(value))
+	}
+
+	return l.evict()
+}
+
+// evict removes the least recently used keys until the bounds are respected, the last set key is kept
+func (l *Local) evict() []string {
+	var evicted []string
+	for l.lru.Len() > 1 && ((l.maxEntries > 0 && l.lru.Len() > l.maxEntries) || (l.maxBytes > 0 && l.size > l.maxBytes)) {
+		elem := l.lru.Back()
+		evicted = append(evicted, elem.Value.(*entry).key)
+		l.remove(elem)
+	}
+	return evicted
+}
+
+func (l *Local) notify(evicted []string) {
+	if l.onEvict == nil {
+		return
+	}
+	for _, key := range evicted {
+		l.onEvict(key)
+	}
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.lookup(key)
+	if e == nil {
+		return []byte(""), nil
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+func (l *Local) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	evicted := l.set(key, value, ttl)
+	l.mu.Unlock()
+
+	l.notify(evicted)
+	return nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	return l.MDelete(ctx, []string{key})
+}
+
+func (l *Local) Exists(ctx context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lookup(key) != nil, nil
+}
+
+func (l *Local) MGet(ctx context.Context, keys []string) (map[string][]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if e := l.lookup(key); e != nil {
+			values[key] = append([]byte(nil), e.value...)
+		}
+	}
+	return values, nil
+}
+
+func (l *Local) MSet(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	var evicted []string
+
+	l.mu.Lock()
+	for key, value := range items {
+		evicted = append(evicted, l.set(key, value, ttl)...)
+	}
+	l.mu.Unlock()
+
+	l.notify(evicted)
+	return nil
+}
+
+func (l *Local) MDelete(ctx context.Context, keys []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := l.entries[key]; ok {
+			l.remove(elem)
+		}
+	}
+	return nil
+}
+
+// TTL follows Redis: -2 for the missing keys, -1 for the keys without expiration
+func (l *Local) TTL(ctx context.Context, key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.lookup(key)
+	switch {
+	case e == nil:
+		return -2, nil
+	case e.expiresAt.IsZero():
+		return -1, nil
+	default:
+		return time.Until(e.expiresAt), nil
+	}
+}
+
+func (l *Local) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e := l.lookup(key); e != nil {
+		e.expiresAt = expiresAt(ttl)
+	}
+	return nil
+}
+
+// Keys returns the keys matching the glob pattern, with the Redis syntax: *, ?, [...] and \ escapes
+func (l *Local) Keys(ctx context.Context, pattern string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	keys := []string{}
+	for key, elem := range l.entries {
+		if !elem.Value.(*entry).expired(now) && utils.MatchGlob(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Scan iterates over a snapshot of the keys matching the pattern, the values are read when iterated
+func (l *Local) Scan(ctx context.Context, pattern string) cache.Iterator {
+	keys, err := l.Keys(ctx, pattern)
+	return &localIterator{cache: l, keys: keys, err: err}
+}
+
+// Lock waits for the key until the context ends, the lock is held for the ttl unless refreshed
+func (l *Local) Lock(ctx context.Context, key string, ttl time.Duration) (cache.FencedLock, error) {
+	return cache.AcquireLock(ctx, func(ctx context.Context) (cache.FencedLock, error) {
+		return l.TryLock(ctx, key, ttl)
+	})
+}
+
+// TryLock makes a single attempt, it fails with cache.ErrLockNotAcquired while another holder has the key
+func (l *Local) TryLock(ctx context.Context, key string, ttl time.Duration) (cache.FencedLock, error) {
+	if ttl <= 0 {
+		return nil, cache.ErrLockTTL
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if previous, ok := l.locks[key]; ok {
+		if !previous.expired(time.Now()) {
+			return nil, cache.ErrLockNotAcquired
+		}
+		previous.lose()
+	}
+
+	l.fence++
+	lock := &localLock{cache: l, key: key, token: l.fence, lost: make(chan struct{})}
+	lock.extend(ttl)
+	l.locks[key] = lock
+	return lock, nil
+}
+
+// Tag adds the key to the tags, which forget it once it is removed
+func (l *Local) Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, tag := range tags {
+		keys, ok := l.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			l.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return nil
+}
+
+func (l *Local) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := []string{}
+	for _, tag := range tags {
+		for key := range l.tags[tag] {
+			if elem, ok := l.entries[key]; ok {
+				l.remove(elem)
+				keys = append(keys, key)
+			}
+		}
+		delete(l.tags, tag)
+	}
+	return keys, nil
+}
+
+func (l *Local) Ping(ctx context.Context) error {
+	select {
+	case <-l.closed:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
+
+// Close stops the removal of the expired keys, the cache stays readable
+func (l *Local) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+// Purge removes every key
+func (l *Local) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = make(map[string]*list.Element)
+	l.tags = make(map[string]map[string]struct{})
+	l.lru.Init()
+	l.size = 0
+}
+
+// Len is the number of keys, the expired ones not yet removed included
+func (l *Local) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lru.Len()
+}
+
+type localIterator struct {
+	cache *Local
+	keys  []string
+	index int
+	value []byte
+	err   error
+}
+
+func (it *localIterator) Next(ctx context.Context) bool {
+	for it.err == nil && it.index < len(it.keys) {
+		it.index++
+
+		it.cache.mu.Lock()
+		e := it.cache.lookup(it.keys[it.index-1])
+		if e != nil {
+			it.value = append([]byte(nil), e.value...)
+		}
+		it.cache.mu.Unlock()
+
+		// the keys removed since the snapshot are skipped
+		if e != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *localIterator) Key() string {
+	if it.index > 0 && it.index <= len(it.keys) {
+		return it.keys[it.index-1]
+	}
+	return ""
+}
+
+func (it *localIterator) Value() []byte {
+	return it.value
+}
+
+func (it *localIterator) Error() error {
+	return it.err
+}
+
+// localLock is not refreshed in background, its holder calls Refresh before the ttl.
+// Lost is closed by a timer when the ttl passes without a refresh.
+type localLock struct {
+	cache     *Local
+	key       string
+	token     int64
+	expiresAt time.Time
+	timer     *time.Timer
+	lost      chan struct{}
+	lostOnce  sync.Once
+}
+
+// extend sets the expiration and its timer, the cache lock is held
+func (l *localLock) extend(ttl time.Duration) {
+	l.expiresAt = expiresAt(ttl)
+
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.AfterFunc(ttl, l.expire)
+}
+
+// expire reports the lock lost and frees the key, unless it was refreshed since the timer fired
+func (l *localLock) expire() {
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+
+	if !l.expired(time.Now()) {
+		return
+	}
+	l.lose()
+	if l.cache.locks[l.key] == l {
+		delete(l.cache.locks, l.key)
+	}
+}
+
+// lose stops the timer and closes Lost, the cache lock is held
+func (l *localLock) lose() {
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.lostOnce.Do(func() { close(l.lost) })
+}
+
+func (l *localLock) expired(now time.Time) bool {
+	return !l.expiresAt.IsZero() && !now.Before(l.expiresAt)
+}
+
+// held reports whether the lock still has the key, the cache lock is held
+func (l *localLock) held() bool {
+	return l.cache.locks[l.key] == l && !l.expired(time.Now())
+}
+
+func (l *localLock) Token() int64 {
+	return l.token
+}
+
+func (l *localLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock releases the key, cache.ErrLockNotHeld reports that it expired before
+func (l *localLock) Unlock(ctx context.Context) error {
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	if !l.held() {
+		return cache.ErrLockNotHeld
+	}
+	delete(l.cache.locks, l.key)
+	return nil
+}
+
+// Refresh extends the lock while it holds the key
+func (l *localLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		return cache.ErrLockTTL
+	}
+
+	l.cache.mu.Lock()
+	defer l.cache.mu.Unlock()
+
+	if !l.held() {
+		l.lose()
+		return cache.ErrLockNotHeld
+	}
+
+	l.extend(ttl)
+	return nil
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	lockMinBackoff = 10 * time.Millisecond
+	lockMaxBackoff = 500 * time.Millisecond
+)
+
+// AcquireLock calls try until it acquires the lock, waiting between the attempts with an exponential backoff.
+// try returns ErrLockNotAcquired while another holder has the key, the other errors stop the attempts.
+func AcquireLock(ctx context.Context, try func(ctx context.Context) (FencedLock, error)) (FencedLock, error) {
+	backoff := lockMinBackoff
+
+	for {
+		lock, err := try(ctx)
+		if !errors.Is(err, ErrLockNotAcquired) {
+			return lock, err
+		}
+
+		// full jitter spreads the attempts of the waiters
+		wait := time.Duration(rand.Int64N(int64(backoff))) + time.Millisecond
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(ErrLockNotAcquired, ctx.Err())
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, lockMaxBackoff)
+	}
+}
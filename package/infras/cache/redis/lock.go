@@ -0,0 +1,189 @@
+package credis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	infras "github.com/gianglt2198/federation-go/package/infras/cache"
+	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+// The lock of a key is stored at lock:{key} with the token of its holder, and its fencing counter at lock:{key}:fence.
+// The hash tag keeps both on the same slot of a cluster, so that the scripts can use them together.
+var (
+	// acquireScript sets the token when the key is free and returns the next fencing token, 0 otherwise
+	acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+	// releaseScript deletes the key if the token still holds it
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+	// refreshScript extends the key if the token still holds it
+	refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+)
+
+func lockKeys(key string) []string {
+	lockKey := "lock:{" + key + "}"
+	return []string{lockKey, lockKey + ":fence"}
+}
+
+// Lock waits for the key until the context ends, then refreshes it every third of the ttl until Unlock.
+// It is safe on a single Redis, or a primary with replicas as long as no failover loses the key.
+func (r *Redis) Lock(ctx context.Context, key string, ttl time.Duration) (infras.FencedLock, error) {
+	return infras.AcquireLock(ctx, func(ctx context.Context) (infras.FencedLock, error) {
+		return r.TryLock(ctx, key, ttl)
+	})
+}
+
+// TryLock makes a single attempt, it fails with cache.ErrLockNotAcquired while another holder has the key
+func (r *Redis) TryLock(ctx context.Context, key string, ttl time.Duration) (infras.FencedLock, error) {
+	if ttl <= 0 {
+		return nil, infras.ErrLockTTL
+	}
+
+	keys := lockKeys(key)
+	value := utils.NewID(32, "lock")
+
+	fence, err := acquireScript.Run(ctx, r.client, keys, value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, infras.ErrLockNotAcquired
+	}
+
+	lock := &redisLock{
+		client: r.client,
+		keys:   keys,
+		value:  value,
+		token:  fence,
+		ttl:    ttl,
+		stop:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+	go lock.keepAlive()
+
+	return lock, nil
+}
+
+// redisLock implements cache.FencedLock for Redis
+type redisLock struct {
+	client redis.Cmdable
+	keys   []string
+	value  string
+	token  int64
+
+	ttl  time.Duration
+	stop chan struct{}
+	lost chan struct{}
+
+	stopOnce sync.Once
+	lostOnce sync.Once
+	mu       sync.Mutex
+}
+
+func (l *redisLock) Token() int64 {
+	return l.token
+}
+
+func (l *redisLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// keepAlive refreshes the lock, and reports it lost once it is taken or no refresh succeeded for a ttl
+func (l *redisLock) keepAlive() {
+	l.mu.Lock()
+	interval := l.ttl / 3
+	l.mu.Unlock()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastRefresh := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		ttl := l.ttl
+		l.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := l.refresh(ctx, ttl)
+		cancel()
+
+		switch {
+		case err == nil:
+			lastRefresh = time.Now()
+		case errors.Is(err, infras.ErrLockNotHeld) || time.Since(lastRefresh) >= ttl:
+			l.lostOnce.Do(func() { close(l.lost) })
+			return
+		}
+	}
+}
+
+func (l *redisLock) refresh(ctx context.Context, ttl time.Duration) error {
+	ok, err := refreshScript.Run(ctx, l.client, l.keys[:1], l.value, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return infras.ErrLockNotHeld
+	}
+	return nil
+}
+
+// Unlock stops the refreshes and releases the key, cache.ErrLockNotHeld reports that it expired before
+func (l *redisLock) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+
+	ok, err := releaseScript.Run(ctx, l.client, l.keys[:1], l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return infras.ErrLockNotHeld
+	}
+	return nil
+}
+
+// Refresh extends the lock to the ttl, which the background refreshes use from then on
+func (l *redisLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		return infras.ErrLockTTL
+	}
+
+	l.mu.Lock()
+	l.ttl = ttl
+	l.mu.Unlock()
+
+	err := l.refresh(ctx, ttl)
+	if errors.Is(err, infras.ErrLockNotHeld) {
+		l.lostOnce.Do(func() { close(l.lost) })
+	}
+	return err
+}
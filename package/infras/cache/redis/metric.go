@@ -1,22 +1,34 @@
 package credis
 
 import (
+	"context"
 	"log"
+	"time"
 
+	redis "github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 
 	"github.com/gianglt2198/federation-go/package/config"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/tracing"
 )
 
+const (
+	metricCacheHits              = "cache_hit_total"
+	metricCacheMisses            = "cache_miss_total"
+	metricCacheOperations        = "cache_operation_total"
+	metricCacheOperationDuration = "cache_operation_duration_milliseconds"
+)
+
 type redisMetric struct {
 	config    config.RedisConfig
 	appConfig config.AppConfig
 
 	// Cache metrics
-	CacheHitsTotal   metric.Int64Counter
-	CacheMissesTotal metric.Int64Counter
-	CacheOperations  metric.Int64Counter
+	CacheHitsTotal    metric.Int64Counter
+	CacheMissesTotal  metric.Int64Counter
+	CacheOperations   metric.Int64Counter
+	OperationDuration metric.Float64Histogram
 }
 
 func NewMetrics(config config.RedisConfig, appConfig config.AppConfig) *redisMetric {
@@ -30,15 +42,15 @@ func NewMetrics(config config.RedisConfig, appConfig config.AppConfig) *redisMet
 	var err error
 
 	rm.CacheHitsTotal, err = m.Int64Counter(
-		"cache_hit_total",
-		metric.WithDescription("Total number of hit cach key received."),
+		metricCacheHits,
+		metric.WithDescription("Total number of hit cache key received."),
 		metric.WithUnit("{requests}"),
 	)
 	if err != nil {
 		log.Fatalf("creating meter cache hit counter failed: %v", err)
 	}
 	rm.CacheMissesTotal, err = m.Int64Counter(
-		"cache_miss_total",
+		metricCacheMisses,
 		metric.WithDescription("Total number of miss cache key received."),
 		metric.WithUnit("{requests}"),
 	)
@@ -46,13 +58,59 @@ func NewMetrics(config config.RedisConfig, appConfig config.AppConfig) *redisMet
 		log.Fatalf("creating meter cache miss counter failed: %v", err)
 	}
 	rm.CacheOperations, err = m.Int64Counter(
-		"cache_operation_total",
+		metricCacheOperations,
 		metric.WithDescription("Total number of cache operation."),
 		metric.WithUnit("{requests}"),
 	)
 	if err != nil {
 		log.Fatalf("creating meter cache operation counter failed: %v", err)
 	}
+	rm.OperationDuration, err = m.Float64Histogram(
+		metricCacheOperationDuration,
+		metric.WithDescription("The duration of a cache command, or of a pipeline."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter cache operation duration failed: %v", err)
+	}
 
 	return rm
 }
+
+// recordLookup counts the keys read by a GET or MGET, found or not
+func (rm *redisMetric) recordLookup(ctx context.Context, command string, hits, misses int) {
+	if rm == nil {
+		return
+	}
+
+	attrs := metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("cache.command", command),
+		attribute.String("service.name", rm.appConfig.Name),
+	))
+	if hits > 0 {
+		rm.CacheHitsTotal.Add(ctx, int64(hits), attrs)
+	}
+	if misses > 0 {
+		rm.CacheMissesTotal.Add(ctx, int64(misses), attrs)
+	}
+}
+
+// recordOperation counts the command and records its latency, redis.Nil is a success
+func (rm *redisMetric) recordOperation(ctx context.Context, command string, err error, duration time.Duration) {
+	if rm == nil {
+		return
+	}
+
+	status := "ok"
+	if err != nil && err != redis.Nil {
+		status = "error"
+	}
+
+	attrs := metric.WithAttributeSet(attribute.NewSet(
+		attribute.String("cache.command", command),
+		attribute.String("service.name", rm.appConfig.Name),
+		attribute.String("status", status),
+	))
+	rm.CacheOperations.Add(ctx, 1, attrs)
+	rm.OperationDuration.Record(ctx, float64(duration.Microseconds())/1000, attrs)
+}
@@ -5,19 +5,14 @@ import (
 
 	"go.uber.org/fx"
 
-	"github.com/gianglt2198/federation-go/package/infras/cache"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 )
 
-// Module provides NATS pubsub client as an fx module
+// Module provides the Redis client as an fx module, the cache.Cache is provided by ctiered.Module
 var Module = []fx.Option{
 	fx.Module("cache",
 		fx.Provide(
 			NewRedis,
-			fx.Annotate(
-				func(client *Redis) cache.Cache { return client },
-				fx.As(new(cache.Cache)),
-			),
 		),
 		fx.Invoke(func(lc fx.Lifecycle, client *Redis, logger *logging.Logger) {
 			if client == nil {
@@ -26,7 +21,7 @@ var Module = []fx.Option{
 
 			lc.Append(fx.Hook{
 				OnStop: func(ctx context.Context) error {
-					logger.GetLogger().Info("Closing NATS connection...")
+					logger.GetLogger().Info("Closing Redis connection...")
 					return client.Close()
 				},
 			})
@@ -2,9 +2,12 @@ package credis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"log"
 	"net"
+	"os"
+	"sync"
 	"time"
 
 	redis "github.com/redis/go-redis/v9"
@@ -14,7 +17,14 @@ import (
 	"github.com/gianglt2198/federation-go/package/config"
 	infras "github.com/gianglt2198/federation-go/package/infras/cache"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
-	"github.com/gianglt2198/federation-go/package/infras/monitoring/tracing"
+)
+
+const (
+	defaultConnectAttempts = 3
+	defaultConnectBackoff  = time.Second
+	maxConnectBackoff      = 30 * time.Second
+	pingTimeout            = 5 * time.Second
+	scanCount              = 100
 )
 
 // Redis represents a Redis client with monitoring
@@ -22,8 +32,12 @@ type Redis struct {
 	config    config.RedisConfig
 	appConfig config.AppConfig
 
-	client *redis.Client
+	client redis.UniversalClient
 	logger *logging.Logger
+	metric *redisMetric
+
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
 type RedisParams struct {
@@ -40,42 +54,32 @@ type RedisResult struct {
 	Redis *Redis
 }
 
-// NewRedis creates a new Redis client
-func NewRedis(params RedisParams) RedisResult {
-	r := connect(params.AppConfig, params.Config, params.Logger)
+// NewRedis creates a new Redis client. An invalid configuration fails the app, while an unreachable Redis
+// only degrades it: the commands fail until the client reconnects in background.
+func NewRedis(params RedisParams) (RedisResult, error) {
+	r, err := connect(params.AppConfig, params.Config, params.Logger)
+	if err != nil {
+		return RedisResult{}, err
+	}
 	return RedisResult{
 		Redis: r,
-	}
+	}, nil
 }
 
 func connect(
 	appConfig config.AppConfig,
 	redisConfig config.RedisConfig,
-	logger *logging.Logger) *Redis {
+	logger *logging.Logger) (*Redis, error) {
 	if !redisConfig.Enabled {
-		return nil
+		return nil, nil
+	}
+	if err := redisConfig.Validate(); err != nil {
+		return nil, err
 	}
-	// Create Redis client
-	client := redis.NewClient(&redis.Options{
-		Addr:         fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port),
-		Password:     redisConfig.Password,
-		DB:           redisConfig.Database,
-		PoolSize:     redisConfig.PoolSize,
-		MinIdleConns: 5,
-		MaxRetries:   3,
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
-		PoolTimeout:  4 * time.Second,
-	})
-
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
 
-	if err := client.Ping(ctx).Err(); err != nil {
-		_ = client.Close()
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	client, err := newClient(redisConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	r := &Redis{
@@ -84,25 +88,167 @@ func connect(
 
 		client: client,
 		logger: logger,
+		metric: NewMetrics(redisConfig, appConfig),
+		closed: make(chan struct{}),
 	}
 
 	// Add hooks for monitoring
 	client.AddHook(&redisHook{
 		logger: logger,
+		metric: r.metric,
 	})
 
-	logger.GetLogger().Info("Redis connection established",
-		zap.String("host", redisConfig.Host),
-		zap.Int("port", redisConfig.Port),
-		zap.Int("database", redisConfig.Database),
-		zap.Int("pool_size", redisConfig.PoolSize),
+	attempts := redisConfig.ConnectAttempts
+	if attempts <= 0 {
+		attempts = defaultConnectAttempts
+	}
+	if err := r.waitReady(attempts); err != nil {
+		logger.GetLogger().Warn("Redis unreachable, starting degraded",
+			zap.String("mode", r.mode()),
+			zap.Strings("addrs", redisConfig.Endpoints()),
+			zap.Error(err),
+		)
+		go func() {
+			if err := r.waitReady(0); err == nil {
+				r.logConnected()
+			}
+		}()
+		return r, nil
+	}
+
+	r.logConnected()
+	return r, nil
+}
+
+// newClient returns the client of the mode: a failover client following the master of the sentinels,
+// a cluster client routing the keys to their node, or a single node client
+func newClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Endpoints(),
+		MasterName:       cfg.MasterName,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		DB:               cfg.Database,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     5,
+		MaxRetries:       3,
+		DialTimeout:      5 * time.Second,
+		ReadTimeout:      3 * time.Second,
+		WriteTimeout:     3 * time.Second,
+		PoolTimeout:      4 * time.Second,
+		TLSConfig:        tlsConfig,
+	}
+
+	switch cfg.Mode {
+	case config.RedisModeSentinel:
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case config.RedisModeCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return redis.NewClient(opts.Simple()), nil
+	}
+}
+
+func newTLSConfig(cfg config.RedisTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // opt-in for development clusters
+	}
+
+	if cfg.CAFile != "" {
+		ca, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read redis tls ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("redis tls ca_file %s has no certificate", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load redis tls client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// waitReady pings Redis with an exponential backoff, attempts 0 retries until Close
+func (r *Redis) waitReady(attempts int) error {
+	delay := r.config.ConnectBackoff
+	if delay <= 0 {
+		delay = defaultConnectBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := r.client.Ping(ctx).Err()
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if attempts > 0 && attempt >= attempts {
+			return fmt.Errorf("connect to redis after %d attempts: %w", attempt, err)
+		}
+
+		r.logger.GetLogger().Warn("Connection to Redis failed, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		select {
+		case <-time.After(delay):
+		case <-r.closed:
+			return redis.ErrClosed
+		}
+		delay = min(delay*2, maxConnectBackoff)
+	}
+}
+
+func (r *Redis) logConnected() {
+	r.logger.GetLogger().Info("Redis connection established",
+		zap.String("mode", r.mode()),
+		zap.Strings("addrs", r.config.Endpoints()),
+		zap.Int("database", r.config.Database),
+		zap.Int("pool_size", r.config.PoolSize),
 	)
+}
+
+func (r *Redis) mode() string {
+	if r.config.Mode == "" {
+		return config.RedisModeSingle
+	}
+	return r.config.Mode
+}
 
-	return r
+// cluster returns the cluster client, the keys of a command must then share a slot
+func (r *Redis) cluster() (*redis.ClusterClient, bool) {
+	c, ok := r.client.(*redis.ClusterClient)
+	return c, ok
 }
 
-// Close closes the Redis connection
+// Close stops the reconnections and closes the Redis connection
 func (r *Redis) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+	})
 	return r.client.Close()
 }
 
@@ -116,16 +262,8 @@ func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
 	start := time.Now()
 	result := r.client.Get(ctx, key)
 
-	// if r.metrics != nil {
-	// 	if result.Err() == redis.Nil {
-	// 		r.metrics.RecordCacheMiss(r.appConfig.Name, "string", key)
-	// 	} else if result.Err() == nil {
-	// 		r.metrics.RecordCacheHit(r.appConfig.Name, "string", key)
-	// 	}
-	// 	r.metrics.RecordCacheOperation(r.appConfig.Name, "string", "get")
-	// }
-
 	if result.Err() == redis.Nil {
+		r.metric.recordLookup(ctx, "get", 0, 1)
 		return []byte(""), nil // Key not found
 	}
 
@@ -141,6 +279,7 @@ func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	r.metric.recordLookup(ctx, "get", 1, 0)
 	return []byte(val), nil
 }
 
@@ -168,17 +307,19 @@ func (r *Redis) Delete(ctx context.Context, key string) error {
 // Del deletes keys from Redis
 func (r *Redis) Del(ctx context.Context, keys ...string) error {
 	start := time.Now()
-	result := r.client.Del(ctx, keys...)
+	_, err := r.sum(ctx, keys, func(c redis.Cmdable, keys ...string) *redis.IntCmd {
+		return c.Del(ctx, keys...)
+	})
 
-	if r.logger != nil && result.Err() != nil {
+	if r.logger != nil && err != nil {
 		r.logger.GetWrappedLogger(ctx).Error("Redis DEL failed",
 			zap.Strings("keys", keys),
-			zap.Error(result.Err()),
+			zap.Error(err),
 			zap.Duration("duration", time.Since(start)),
 		)
 	}
 
-	return result.Err()
+	return err
 }
 
 // Exists checks if a key exists in Redis (implements Cache interface)
@@ -190,17 +331,44 @@ func (r *Redis) Exists(ctx context.Context, key string) (bool, error) {
 // ExistsMultiple checks if keys exist in Redis
 func (r *Redis) ExistsMultiple(ctx context.Context, keys ...string) (int64, error) {
 	start := time.Now()
-	result := r.client.Exists(ctx, keys...)
+	count, err := r.sum(ctx, keys, func(c redis.Cmdable, keys ...string) *redis.IntCmd {
+		return c.Exists(ctx, keys...)
+	})
 
-	if r.logger != nil && result.Err() != nil {
+	if r.logger != nil && err != nil {
 		r.logger.GetWrappedLogger(ctx).Error("Redis EXISTS failed",
 			zap.Strings("keys", keys),
-			zap.Error(result.Err()),
+			zap.Error(err),
 			zap.Duration("duration", time.Since(start)),
 		)
 	}
 
-	return result.Result()
+	return count, err
+}
+
+// sum runs a counting command on the keys. On a cluster the keys of different slots are sent
+// one by one in a pipeline, which the client splits by node, instead of failing with CROSSSLOT.
+func (r *Redis) sum(ctx context.Context, keys []string, command func(c redis.Cmdable, keys ...string) *redis.IntCmd) (int64, error) {
+	cluster, ok := r.cluster()
+	if !ok || len(keys) <= 1 {
+		return command(r.client, keys...).Result()
+	}
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	if _, err := cluster.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = command(pipe, key)
+		}
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return total, nil
 }
 
 // HGet retrieves a field from a hash
@@ -208,8 +376,6 @@ func (r *Redis) HGet(ctx context.Context, key, field string) (string, error) {
 	start := time.Now()
 	result := r.client.HGet(ctx, key, field)
 
-	tracing.Meter("redis")
-
 	if result.Err() == redis.Nil {
 		return "", nil // Field not found
 	}
@@ -276,14 +442,39 @@ func (r *Redis) Expire(ctx context.Context, key string, expiration time.Duration
 	return result.Err()
 }
 
-// MGet retrieves multiple values from Redis
+// MGet retrieves multiple values from Redis, with a pipeline of GET on a cluster
 func (r *Redis) MGet(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	if cluster, ok := r.cluster(); ok {
+		cmds := make([]*redis.StringCmd, len(keys))
+		_, err := cluster.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		for i, cmd := range cmds {
+			if cmd.Err() == nil {
+				values[keys[i]] = []byte(cmd.Val())
+			}
+		}
+		r.metric.recordLookup(ctx, "mget", len(values), len(keys)-len(values))
+		return values, nil
+	}
+
 	result := r.client.MGet(ctx, keys...)
 	if result.Err() != nil {
 		return nil, result.Err()
 	}
 
-	values := make(map[string][]byte)
 	for i, val := range result.Val() {
 		if val != nil {
 			if str, ok := val.(string); ok {
@@ -291,6 +482,7 @@ func (r *Redis) MGet(ctx context.Context, keys []string) (map[string][]byte, err
 			}
 		}
 	}
+	r.metric.recordLookup(ctx, "mget", len(values), len(keys)-len(values))
 	return values, nil
 }
 
@@ -310,33 +502,57 @@ func (r *Redis) MDelete(ctx context.Context, keys []string) error {
 }
 
 // TTL returns the time to live for a key
+// TTL is read in milliseconds, the tiered cache expires its local copies with it
 func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
-	result := r.client.TTL(ctx, key)
+	result := r.client.PTTL(ctx, key)
 	return result.Val(), result.Err()
 }
 
-// Keys returns keys matching a pattern
+// Keys returns keys matching a pattern, from every master of a cluster
 func (r *Redis) Keys(ctx context.Context, pattern string) ([]string, error) {
-	result := r.client.Keys(ctx, pattern)
-	return result.Val(), result.Err()
+	cluster, ok := r.cluster()
+	if !ok {
+		result := r.client.Keys(ctx, pattern)
+		return result.Val(), result.Err()
+	}
+
+	var mu sync.Mutex
+	keys := []string{}
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		result, err := node.Keys(ctx, pattern).Result()
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		keys = append(keys, result...)
+		mu.Unlock()
+		return nil
+	})
+	return keys, err
 }
 
-// Scan returns an iterator for keys matching a pattern
+// Scan returns an iterator for keys matching a pattern, scanning the masters of a cluster one after the other
 func (r *Redis) Scan(ctx context.Context, pattern string) infras.Iterator {
-	return &redisIterator{
+	it := &redisIterator{
 		client:  r.client,
+		nodes:   []redis.Cmdable{r.client},
 		pattern: pattern,
 		ctx:     ctx,
 	}
-}
 
-// Lock creates a distributed lock
-func (r *Redis) Lock(ctx context.Context, key string, ttl time.Duration) (infras.Lock, error) {
-	return &redisLock{
-		client: r.client,
-		key:    key,
-		ttl:    ttl,
-	}, nil
+	if cluster, ok := r.cluster(); ok {
+		var mu sync.Mutex
+		it.nodes = nil
+		it.err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			mu.Lock()
+			it.nodes = append(it.nodes, node)
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	return it
 }
 
 // HealthCheck returns a health check function for Redis
@@ -346,13 +562,16 @@ func (r *Redis) HealthCheck() func(ctx context.Context) error {
 	}
 }
 
-func (r *Redis) GetClient() *redis.Client {
+// GetClient returns the client of the mode, a *redis.ClusterClient in cluster mode and a *redis.Client otherwise
+func (r *Redis) GetClient() redis.UniversalClient {
 	return r.client
 }
 
 // redisIterator implements infras.Iterator for Redis SCAN
 type redisIterator struct {
-	client  *redis.Client
+	client  redis.UniversalClient
+	nodes   []redis.Cmdable
+	node    int
 	pattern string
 	ctx     context.Context
 	cursor  uint64
@@ -362,23 +581,26 @@ type redisIterator struct {
 }
 
 func (it *redisIterator) Next(ctx context.Context) bool {
-	if it.index < len(it.keys) {
-		it.index++
-		return it.index <= len(it.keys)
-	}
+	// the batches of SCAN may be empty before the end of the node
+	for it.index >= len(it.keys) {
+		if it.err != nil || it.node >= len(it.nodes) {
+			return false
+		}
 
-	result := it.client.Scan(ctx, it.cursor, it.pattern, 10)
-	if result.Err() != nil {
-		it.err = result.Err()
-		return false
+		keys, cursor, err := it.nodes[it.node].Scan(ctx, it.cursor, it.pattern, scanCount).Result()
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.keys, it.index, it.cursor = keys, 0, cursor
+		if cursor == 0 {
+			it.node++
+		}
 	}
 
-	keys, cursor := result.Val()
-	it.keys = append(it.keys, keys...)
-	it.cursor = cursor
 	it.index++
-
-	return it.index <= len(it.keys) || cursor != 0
+	return true
 }
 
 func (it *redisIterator) Key() string {
@@ -405,21 +627,6 @@ func (it *redisIterator) Error() error {
 	return it.err
 }
 
-// redisLock implements infras.Lock for Redis
-type redisLock struct {
-	client *redis.Client
-	key    string
-	ttl    time.Duration
-}
-
-func (l *redisLock) Unlock(ctx context.Context) error {
-	return l.client.Del(ctx, l.key).Err()
-}
-
-func (l *redisLock) Refresh(ctx context.Context, ttl time.Duration) error {
-	return l.client.Expire(ctx, l.key, ttl).Err()
-}
-
 // redisHook implements redis.Hook for monitoring
 type redisHook struct {
 	logger *logging.Logger
@@ -456,11 +663,10 @@ func (h *redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
 	return func(ctx context.Context, cmd redis.Cmder) error {
 		start := time.Now()
 		err := next(ctx, cmd)
-		duration := time.Since(start).Milliseconds()
+		elapsed := time.Since(start)
+		duration := elapsed.Milliseconds()
 
-		if h.metric != nil {
-			h.metric.CacheOperations.Add(ctx, 1)
-		}
+		h.metric.recordOperation(ctx, cmd.Name(), err, elapsed)
 
 		if h.logger != nil {
 			h.logger.GetWrappedLogger(ctx).Debug("Redis command executed",
@@ -479,11 +685,10 @@ func (h *redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.Pr
 	return func(ctx context.Context, cmds []redis.Cmder) error {
 		start := time.Now()
 		err := next(ctx, cmds)
-		duration := time.Since(start).Milliseconds()
+		elapsed := time.Since(start)
+		duration := elapsed.Milliseconds()
 
-		if h.metric != nil {
-			h.metric.CacheOperations.Add(ctx, 1)
-		}
+		h.metric.recordOperation(ctx, "pipeline", err, elapsed)
 
 		if h.logger != nil {
 			h.logger.GetWrappedLogger(ctx).Debug("Redis pipeline executed",
@@ -0,0 +1,71 @@
+package credis
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	infras "github.com/gianglt2198/federation-go/package/infras/cache"
+)
+
+// The keys of a tag are stored in the set tag:{tag}. The set lives as long as its longest lived key,
+// so that it never forgets a key still cached.
+var tagScript = redis.NewScript(`
+local existed = redis.call("EXISTS", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[2])
+local ttl = tonumber(ARGV[1])
+if ttl <= 0 then
+	return redis.call("PERSIST", KEYS[1])
+end
+local current = redis.call("PTTL", KEYS[1])
+if existed == 0 or (current >= 0 and current < ttl) then
+	return redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 0
+`)
+
+var _ infras.Tagger = (*Redis)(nil)
+
+func tagKey(tag string) string {
+	return "tag:{" + tag + "}"
+}
+
+// Tag adds the key to the sets of the tags
+func (r *Redis) Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	for _, tag := range tags {
+		if err := tagScript.Run(ctx, r.client, []string{tagKey(tag)}, ttl.Milliseconds(), key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTags deletes the keys of the sets of the tags, then the sets. A key tagged in between
+// is left cached, the tags are invalidated after the change of the entity they cache.
+func (r *Redis) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	seen := map[string]struct{}{}
+	keys := []string{}
+	sets := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		set := tagKey(tag)
+		sets = append(sets, set)
+
+		members, err := r.client.SMembers(ctx, set).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if _, ok := seen[member]; !ok {
+				seen[member] = struct{}{}
+				keys = append(keys, member)
+			}
+		}
+	}
+
+	if err := r.Del(ctx, append(append([]string{}, keys...), sets...)...); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrTagsNotSupported is returned by Tag and InvalidateTags for the caches which are not a Tagger
+var ErrTagsNotSupported = errors.New("cache: tags not supported")
+
+// Tagger groups the keys under tags, e.g. "product:prod123", so that every key caching an entity
+// is invalidated at once, whatever query or resolver cached it
+type Tagger interface {
+	// Tag adds the key to the tags, kept at least for the ttl, 0 keeps them until invalidated
+	Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) error
+	// InvalidateTags deletes the keys of the tags and the tags, and returns the deleted keys
+	InvalidateTags(ctx context.Context, tags ...string) ([]string, error)
+}
+
+// EntityTag is the tag of the keys caching an entity, e.g. "product:prod123"
+func EntityTag(entity, id string) string {
+	return strings.ToLower(entity) + keySeparator + id
+}
+
+// Tag adds the key to the tags of the cache
+func Tag(ctx context.Context, c Cache, key string, ttl time.Duration, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tagger, ok := c.(Tagger)
+	if !ok {
+		return ErrTagsNotSupported
+	}
+	return tagger.Tag(ctx, key, ttl, tags...)
+}
+
+// InvalidateTags deletes the keys of the tags from the cache
+func InvalidateTags(ctx context.Context, c Cache, tags ...string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	tagger, ok := c.(Tagger)
+	if !ok {
+		return nil, ErrTagsNotSupported
+	}
+	return tagger.InvalidateTags(ctx, tags...)
+}
@@ -0,0 +1,72 @@
+package ctiered
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/cache"
+	clocal "github.com/gianglt2198/federation-go/package/infras/cache/local"
+	credis "github.com/gianglt2198/federation-go/package/infras/cache/redis"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+)
+
+// Module provides the cache.Cache of the configuration: Redis, the local cache, or the local cache in front of Redis
+var Module = []fx.Option{
+	fx.Module("cache.tiered",
+		fx.Provide(NewCache),
+		fx.Invoke(func(lc fx.Lifecycle, c cache.Cache, logger *logging.Logger) {
+			// Redis is closed by its module
+			if _, ok := c.(*credis.Redis); c == nil || ok {
+				return
+			}
+
+			lc.Append(fx.Hook{
+				OnStart: func(ctx context.Context) error {
+					if tiered, ok := c.(*Tiered); ok {
+						tiered.Start()
+					}
+					return nil
+				},
+				OnStop: func(ctx context.Context) error {
+					logger.GetLogger().Info("Closing local cache...")
+					return c.Close()
+				},
+			})
+		}),
+	),
+}
+
+type CacheParams struct {
+	fx.In
+
+	Config config.CacheConfig
+	Log    *logging.Logger
+	Redis  *credis.Redis `optional:"true"`
+	Client pubsub.Client `optional:"true"`
+}
+
+// NewCache returns nil when neither Redis nor the local cache are enabled
+func NewCache(params CacheParams) cache.Cache {
+	cfg := params.Config.Local
+
+	if !cfg.Enabled {
+		if params.Redis == nil {
+			return nil
+		}
+		return params.Redis
+	}
+
+	local := clocal.New(cfg.CleanupInterval,
+		clocal.WithMaxEntries(cfg.MaxEntries),
+		clocal.WithMaxBytes(cfg.MaxBytes),
+	)
+
+	if params.Redis == nil {
+		return local
+	}
+
+	return NewTiered(local, params.Redis, cfg.TTL, params.Client, cfg.InvalidationTopic, params.Log)
+}
@@ -0,0 +1,321 @@
+package ctiered
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/infras/cache"
+	clocal "github.com/gianglt2198/federation-go/package/infras/cache/local"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+const (
+	defaultInvalidationTopic = "cache.invalidate"
+	// generationStripes is the number of generations the keys are hashed in
+	generationStripes = 256
+)
+
+type (
+	// Tiered reads through the local cache to the remote one. The writes go to the remote cache first,
+	// then evict the key from the local cache of every instance with a message on the invalidation topic.
+	Tiered struct {
+		local  *clocal.Local
+		remote cache.Cache
+		log    *logging.Logger
+
+		// localTTL bounds how long the remote values are kept in process, 0 keeps them for their remote TTL.
+		// The values read from the remote cache never outlive their remote TTL.
+		localTTL time.Duration
+
+		client   pubsub.Client
+		topic    string
+		instance string
+		cancel   context.CancelFunc
+
+		// an eviction bumps the generation of its keys, so that the remote values read before it
+		// are not kept in process after it. The keys share the generations of their stripe.
+		evictions   sync.RWMutex
+		generations [generationStripes]atomic.Uint64
+	}
+
+	// invalidation is the message of the invalidation topic
+	invalidation struct {
+		Instance string   `json:"instance"`
+		Keys     []string `json:"keys"`
+	}
+)
+
+var (
+	_ cache.Cache  = (*Tiered)(nil)
+	_ cache.Tagger = (*Tiered)(nil)
+)
+
+// NewTiered puts the local cache in front of the remote one, the invalidations are only local without client
+func NewTiered(local *clocal.Local, remote cache.Cache, localTTL time.Duration, client pubsub.Client, topic string, log *logging.Logger) *Tiered {
+	if topic == "" {
+		topic = defaultInvalidationTopic
+	}
+
+	return &Tiered{
+		local:    local,
+		remote:   remote,
+		log:      log,
+		localTTL: localTTL,
+		client:   client,
+		topic:    topic,
+		instance: utils.NewID(32, "cache"),
+	}
+}
+
+// Start subscribes to the invalidations of the other instances
+func (t *Tiered) Start() {
+	if t.client == nil {
+		return
+	}
+
+	var ctx context.Context
+	ctx, t.cancel = context.WithCancel(context.Background())
+	t.client.Subscribe(ctx, t.topic, func(ctx context.Context, msg pubsub.Message) (any, error) {
+		var inv invalidation
+		if err := json.Unmarshal(msg.Data, &inv); err != nil {
+			return nil, err
+		}
+		if inv.Instance == t.instance {
+			return nil, nil
+		}
+		return nil, t.evict(ctx, inv.Keys)
+	})
+}
+
+func (t *Tiered) generation(key string) *atomic.Uint64 {
+	// FNV-1a
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return &t.generations[hash%generationStripes]
+}
+
+// evict deletes the keys from the local cache and bumps their generation
+func (t *Tiered) evict(ctx context.Context, keys []string) error {
+	t.evictions.Lock()
+	defer t.evictions.Unlock()
+
+	for _, key := range keys {
+		t.generation(key).Add(1)
+	}
+	return t.local.MDelete(ctx, keys)
+}
+
+// fill keeps a value read from the remote cache in process, unless the key was evicted since its
+// generation was read before the remote read
+func (t *Tiered) fill(ctx context.Context, key string, value []byte, generation uint64) {
+	ttl, ok := t.remoteExpiration(ctx, key)
+	if !ok {
+		return
+	}
+
+	t.evictions.RLock()
+	defer t.evictions.RUnlock()
+
+	if t.generation(key).Load() != generation {
+		return
+	}
+	_ = t.local.Set(ctx, key, value, ttl)
+}
+
+// invalidate evicts the keys locally and from the other instances, a lost message leaves them until their local TTL
+func (t *Tiered) invalidate(ctx context.Context, keys ...string) {
+	_ = t.evict(ctx, keys)
+
+	if t.client == nil || len(keys) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(invalidation{Instance: t.instance, Keys: keys})
+	if err == nil {
+		err = t.client.Publish(ctx, t.topic, data, nil)
+	}
+	if err != nil && t.log != nil {
+		t.log.GetWrappedLogger(ctx).Warn("Cache invalidation not published", zap.Strings("keys", keys), zap.Error(err))
+	}
+}
+
+func (t *Tiered) localExpiration(ttl time.Duration) time.Duration {
+	if t.localTTL > 0 && (ttl <= 0 || ttl > t.localTTL) {
+		return t.localTTL
+	}
+	return ttl
+}
+
+// remoteExpiration returns the local TTL of a value read from the remote cache, its remote TTL bounded by the
+// local one. The value is not kept in process when its remote TTL cannot be read.
+func (t *Tiered) remoteExpiration(ctx context.Context, key string) (time.Duration, bool) {
+	ttl, err := t.remote.TTL(ctx, key)
+	switch {
+	case err != nil:
+		return 0, false
+	case ttl > 0:
+		return t.localExpiration(ttl), true
+	case ttl == -1:
+		// no remote expiry
+		return t.localExpiration(0), true
+	default:
+		// expired since the read
+		return 0, false
+	}
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) ([]byte, error) {
+	if value, _ := t.local.Get(ctx, key); len(value) > 0 {
+		return value, nil
+	}
+
+	generation := t.generation(key).Load()
+	value, err := t.remote.Get(ctx, key)
+	if err != nil || len(value) == 0 {
+		return value, err
+	}
+
+	t.fill(ctx, key, value, generation)
+	return value, nil
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	t.invalidate(ctx, key)
+	return t.local.Set(ctx, key, value, t.localExpiration(ttl))
+}
+
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	if err := t.remote.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	t.invalidate(ctx, key)
+	return nil
+}
+
+func (t *Tiered) Exists(ctx context.Context, key string) (bool, error) {
+	if ok, _ := t.local.Exists(ctx, key); ok {
+		return true, nil
+	}
+	return t.remote.Exists(ctx, key)
+}
+
+// MGet reads the missing keys from the remote cache in a single call, then the TTL of each to keep it locally
+func (t *Tiered) MGet(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values, _ := t.local.MGet(ctx, keys)
+
+	missing := make([]string, 0, len(keys)-len(values))
+	generations := make(map[string]uint64, len(keys)-len(values))
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missing = append(missing, key)
+			generations[key] = t.generation(key).Load()
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	remote, err := t.remote.MGet(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range remote {
+		t.fill(ctx, key, value, generations[key])
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (t *Tiered) MSet(ctx context.Context, items map[string][]byte, ttl time.Duration) error {
+	if err := t.remote.MSet(ctx, items, ttl); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	t.invalidate(ctx, keys...)
+
+	return t.local.MSet(ctx, items, t.localExpiration(ttl))
+}
+
+func (t *Tiered) MDelete(ctx context.Context, keys []string) error {
+	if err := t.remote.MDelete(ctx, keys); err != nil {
+		return err
+	}
+
+	t.invalidate(ctx, keys...)
+	return nil
+}
+
+func (t *Tiered) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return t.remote.TTL(ctx, key)
+}
+
+func (t *Tiered) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if err := t.remote.Expire(ctx, key, ttl); err != nil {
+		return err
+	}
+
+	t.invalidate(ctx, key)
+	return nil
+}
+
+// Tag is shared by the instances, so it is stored with the remote cache
+func (t *Tiered) Tag(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	return cache.Tag(ctx, t.remote, key, ttl, tags...)
+}
+
+// InvalidateTags deletes the keys of the tags from the remote cache, then from the local cache of every instance
+func (t *Tiered) InvalidateTags(ctx context.Context, tags ...string) ([]string, error) {
+	keys, err := cache.InvalidateTags(ctx, t.remote, tags...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.invalidate(ctx, keys...)
+	return keys, nil
+}
+
+// Keys and Scan see every instance's keys, they go to the remote cache
+func (t *Tiered) Keys(ctx context.Context, pattern string) ([]string, error) {
+	return t.remote.Keys(ctx, pattern)
+}
+
+func (t *Tiered) Scan(ctx context.Context, pattern string) cache.Iterator {
+	return t.remote.Scan(ctx, pattern)
+}
+
+// Lock excludes the other instances, so it is taken on the remote cache
+func (t *Tiered) Lock(ctx context.Context, key string, ttl time.Duration) (cache.FencedLock, error) {
+	return t.remote.Lock(ctx, key, ttl)
+}
+
+func (t *Tiered) Ping(ctx context.Context) error {
+	return t.remote.Ping(ctx)
+}
+
+// Close stops the invalidations and the local cache, the remote one is closed by its module
+func (t *Tiered) Close() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return t.local.Close()
+}
@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/gianglt2198/federation-go/package/infras/serdes"
+)
+
+// ErrNotFound is returned by the loaders of the missing values, it is cached with the negative TTL
+var ErrNotFound = errors.New("cache: not found")
+
+// loads shares the loads of a key between the concurrent callers of the process, see loadKey
+var loads singleflight.Group
+
+type (
+	// Loader loads the value of a key missing from the cache
+	Loader[T any] func(ctx context.Context) (T, error)
+
+	LoadOption func(*loadOptions)
+
+	loadOptions struct {
+		serializer   serdes.Serializer
+		negativeTTL  time.Duration
+		staleTTL     time.Duration
+		earlyRefresh float64
+		tags         []string
+	}
+
+	// entry is the stored value, with what the refresh decisions need
+	entry[T any] struct {
+		Value     T     `json:"v"`
+		NotFound  bool  `json:"nf,omitempty"`
+		ExpiresAt int64 `json:"exp"`
+		// Delta is the duration of the load, the longer loads are refreshed earlier
+		Delta int64 `json:"d"`
+	}
+)
+
+// WithSerializer encodes the values, msgpack by default
+func WithSerializer(s serdes.Serializer) LoadOption {
+	return func(o *loadOptions) {
+		o.serializer = s
+	}
+}
+
+// WithNegativeTTL caches the ErrNotFound of the loader for the ttl, the misses are not cached by default
+func WithNegativeTTL(ttl time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithStaleWhileRevalidate keeps serving the value up to ttl after its expiration, while it is reloaded in background
+func WithStaleWhileRevalidate(ttl time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.staleTTL = ttl
+	}
+}
+
+// WithEarlyRefresh reloads the values in background before their expiration, with a probability
+// growing as it comes closer (XFetch). beta is 1 usually, greater values refresh earlier.
+func WithEarlyRefresh(beta float64) LoadOption {
+	return func(o *loadOptions) {
+		o.earlyRefresh = beta
+	}
+}
+
+// WithTags tags the stored values, the misses cached by WithNegativeTTL included,
+// e.g. with the EntityTag of the entity so that its change invalidates them
+func WithTags(tags ...string) LoadOption {
+	return func(o *loadOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+func newLoadOptions(opts []LoadOption) loadOptions {
+	o := loadOptions{serializer: serdes.NewMsgPack()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Get decodes the value of the key, found is false for the missing, negative and stale keys
+func Get[T any](ctx context.Context, c Cache, key string, opts ...LoadOption) (value T, found bool, err error) {
+	o := newLoadOptions(opts)
+
+	e, err := read[T](ctx, c, key, o)
+	if err != nil || e == nil || e.NotFound || (e.ExpiresAt != 0 && time.Now().UnixNano() >= e.ExpiresAt) {
+		return value, false, err
+	}
+	return e.Value, true, nil
+}
+
+// Set encodes the value of the key
+func Set[T any](ctx context.Context, c Cache, key string, value T, ttl time.Duration, opts ...LoadOption) error {
+	o := newLoadOptions(opts)
+	return write(ctx, c, key, &entry[T]{Value: value, ExpiresAt: expiresAt(ttl)}, ttl, o)
+}
+
+// GetOrLoad returns the cached value of the key, or loads and caches it. The concurrent loads of a key
+// are shared by the callers of the process, and the cache errors fall back to the loader.
+func GetOrLoad[T any](ctx context.Context, c Cache, key string, ttl time.Duration, loader Loader[T], opts ...LoadOption) (T, error) {
+	o := newLoadOptions(opts)
+
+	e, err := read[T](ctx, c, key, o)
+	if err != nil || e == nil {
+		return load(ctx, c, key, ttl, loader, o)
+	}
+
+	now := time.Now()
+	switch {
+	case e.ExpiresAt != 0 && now.UnixNano() >= e.ExpiresAt:
+		// stale, within the stale window since it is still stored
+		refresh(ctx, c, key, ttl, loader, o)
+	case o.earlyRefresh > 0 && e.ExpiresAt != 0 && shouldRefreshEarly(now, e, o.earlyRefresh):
+		refresh(ctx, c, key, ttl, loader, o)
+	}
+
+	if e.NotFound {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return e.Value, nil
+}
+
+// shouldRefreshEarly is the XFetch test: now - delta * beta * ln(rand) >= expiration
+func shouldRefreshEarly[T any](now time.Time, e *entry[T], beta float64) bool {
+	gap := -float64(e.Delta) * beta * math.Log(1-rand.Float64())
+	return float64(now.UnixNano())+gap >= float64(e.ExpiresAt)
+}
+
+// refresh loads the value in background, once per key
+func refresh[T any](ctx context.Context, c Cache, key string, ttl time.Duration, loader Loader[T], o loadOptions) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		_, _ = load(ctx, c, key, ttl, loader, o)
+	}()
+}
+
+// loadKey shares a load between the callers of the same cache, key and value type only
+func loadKey[T any](c Cache, key string) string {
+	return fmt.Sprintf("%p/%v/%s", c, reflect.TypeFor[T](), key)
+}
+
+// load runs the loader once for the concurrent callers. It is not canceled with the caller which started it,
+// the others are waiting for it, but every caller returns when its own context ends.
+func load[T any](ctx context.Context, c Cache, key string, ttl time.Duration, loader Loader[T], o loadOptions) (T, error) {
+	loadCtx := context.WithoutCancel(ctx)
+	results := loads.DoChan(loadKey[T](c, key), func() (any, error) {
+		start := time.Now()
+		value, err := loader(loadCtx)
+		delta := time.Since(start).Nanoseconds()
+
+		switch {
+		case errors.Is(err, ErrNotFound):
+			if o.negativeTTL > 0 {
+				_ = write(loadCtx, c, key, &entry[T]{NotFound: true, ExpiresAt: expiresAt(o.negativeTTL), Delta: delta}, o.negativeTTL, o)
+			}
+			return value, err
+		case err != nil:
+			return value, err
+		}
+
+		_ = write(loadCtx, c, key, &entry[T]{Value: value, ExpiresAt: expiresAt(ttl), Delta: delta}, ttl, o)
+		return value, nil
+	})
+
+	var res singleflight.Result
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case res = <-results:
+	}
+
+	typed, ok := res.Val.(T)
+	if !ok && res.Val != nil {
+		return typed, fmt.Errorf("cache: load of %s returned %T, %v expected", key, res.Val, reflect.TypeFor[T]())
+	}
+	return typed, res.Err
+}
+
+func read[T any](ctx context.Context, c Cache, key string, o loadOptions) (*entry[T], error) {
+	data, err := c.Get(ctx, key)
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+
+	e := &entry[T]{}
+	if err := o.serializer.Decode(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// write stores and tags the entry for its ttl and the stale window, the entries without ttl never expire
+func write[T any](ctx context.Context, c Cache, key string, e *entry[T], ttl time.Duration, o loadOptions) error {
+	data, err := o.serializer.Encode(e)
+	if err != nil {
+		return err
+	}
+
+	if ttl > 0 {
+		ttl += o.staleTTL
+	}
+	if err := c.Set(ctx, key, data, ttl); err != nil {
+		return err
+	}
+	return Tag(ctx, c, key, ttl, o.tags...)
+}
+
+func expiresAt(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
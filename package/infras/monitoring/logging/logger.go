@@ -18,8 +18,8 @@ type Logger struct {
 	serviceName string
 }
 
-// NewLogger creates a new logger instance
-func NewLogger(config config.AppConfig, natsConfg config.NATSConfig) *Logger {
+// NewLogger creates a new logger instance, it fails when the production logs cannot be shipped to NATS
+func NewLogger(config config.AppConfig, natsConfg config.NATSConfig) (*Logger, error) {
 	var coreArr []zapcore.Core
 
 	if config.Environment == "production" {
@@ -28,7 +28,10 @@ func NewLogger(config config.AppConfig, natsConfg config.NATSConfig) *Logger {
 		encoder := zapcore.NewJSONEncoder(encoderConfig)
 
 		if natsConfg.Enabled {
-			natsCore := NewNatsCore(natsConfg) // Replace with your NATS logging subject
+			natsCore, err := NewNatsCore(natsConfg) // Replace with your NATS logging subject
+			if err != nil {
+				return nil, err
+			}
 			natsLogCore := zapcore.NewCore(encoder, natsCore, zapcore.InfoLevel)
 			coreArr = append(coreArr, natsLogCore)
 		} else {
@@ -50,7 +53,7 @@ func NewLogger(config config.AppConfig, natsConfg config.NATSConfig) *Logger {
 	return &Logger{
 		serviceName: config.Name,
 		Logger:      log,
-	}
+	}, nil
 }
 
 func (l *Logger) GetLogger() *zap.Logger { return l.Logger }
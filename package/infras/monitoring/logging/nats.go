@@ -1,11 +1,14 @@
 package logging
 
 import (
+	"errors"
+	"fmt"
 	"sync"
 
 	nats "github.com/nats-io/nats.go"
 
 	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natsconn"
 )
 
 type NatsCore struct {
@@ -14,27 +17,37 @@ type NatsCore struct {
 	sync.Mutex
 }
 
-func NewNatsCore(config config.NATSConfig) *NatsCore {
-	if config.Endpoint == "" {
-		return nil
+func NewNatsCore(config config.NATSConfig) (*NatsCore, error) {
+	if config.URLs() == "" {
+		return nil, errors.New("nats logging: no endpoint")
 	}
 
-	nc, err := nats.Connect(config.Endpoint)
+	opts, err := natsconn.Options(config.Authentication, config.TLS)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("nats logging: connection options: %w", err)
+	}
+
+	nc, err := nats.Connect(config.URLs(), append(opts, nats.Name(config.Name))...)
+	if err != nil {
+		return nil, fmt.Errorf("nats logging: connect: %w", err)
 	}
 	subject := "logging"
 	return &NatsCore{
 		subject: subject,
 		nc:      nc,
-	}
+	}, nil
 }
 
 func (n *NatsCore) Write(p []byte) (int, error) {
 	n.Lock()
 	defer n.Unlock()
 
-	if err := n.nc.Publish(n.subject, p); err != nil {
+	// the entries are JSON, declared like the psnats messages so that any consumer can read them
+	msg := nats.NewMsg(n.subject)
+	msg.Header.Set("content-type", "application/json")
+	msg.Data = p
+
+	if err := n.nc.PublishMsg(msg); err != nil {
 		return 0, err
 	}
 	return len(p), nil
@@ -0,0 +1,117 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
+)
+
+// CloudEvents attributes, carried as message headers
+const (
+	HeaderID              = "ce_id"
+	HeaderSource          = "ce_source"
+	HeaderType            = "ce_type"
+	HeaderTime            = "ce_time"
+	HeaderSpecVersion     = "ce_specversion"
+	HeaderDataContentType = "ce_datacontenttype"
+
+	SpecVersion = "1.0"
+)
+
+type (
+	// Typed is implemented by the events naming their type, e.g. "account.user.created".
+	// The other events are named after their Go type.
+	Typed interface {
+		EventType() string
+	}
+
+	// Versioned is implemented by the events whose schema evolves, the version suffixes the type,
+	// e.g. "account.user.created.v2". The events without version are at version 1.
+	Versioned interface {
+		EventVersion() int
+	}
+
+	// Envelope is the CloudEvents context of an event
+	Envelope struct {
+		ID          string
+		Source      string
+		Type        string
+		Name        string
+		Version     int
+		Time        time.Time
+		ContentType string
+	}
+)
+
+// EnvelopeFromContext returns the envelope of the handled event,
+// false when the message was not published by an event bus
+func EnvelopeFromContext(ctx context.Context) (Envelope, bool) {
+	envelope := Envelope{
+		ID:          psnats.HeaderFromContext(ctx, HeaderID),
+		Source:      psnats.HeaderFromContext(ctx, HeaderSource),
+		Type:        psnats.HeaderFromContext(ctx, HeaderType),
+		ContentType: psnats.HeaderFromContext(ctx, HeaderDataContentType),
+	}
+	if envelope.Type == "" {
+		return Envelope{}, false
+	}
+
+	envelope.Name, envelope.Version = parseEventType(envelope.Type)
+	if t, err := time.Parse(time.RFC3339Nano, psnats.HeaderFromContext(ctx, HeaderTime)); err == nil {
+		envelope.Time = t
+	}
+	return envelope, true
+}
+
+func (e Envelope) headers() map[string]string {
+	return map[string]string{
+		HeaderID:              e.ID,
+		HeaderSource:          e.Source,
+		HeaderType:            e.Type,
+		HeaderTime:            e.Time.Format(time.RFC3339Nano),
+		HeaderSpecVersion:     SpecVersion,
+		HeaderDataContentType: e.ContentType,
+	}
+}
+
+// eventType returns the name and the version of the events of type t
+func eventType(t reflect.Type) (string, int) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	name := t.Name()
+
+	// a pointer implements the methods of both receivers
+	event := reflect.New(t).Interface()
+	if typed, ok := event.(Typed); ok {
+		name = typed.EventType()
+	}
+
+	version := 1
+	if versioned, ok := event.(Versioned); ok {
+		version = versioned.EventVersion()
+	}
+	return name, version
+}
+
+func formatEventType(name string, version int) string {
+	return fmt.Sprintf("%s.v%d", name, version)
+}
+
+// parseEventType splits the version suffix of the type, the types without it are at version 1
+func parseEventType(eventType string) (string, int) {
+	i := strings.LastIndex(eventType, ".v")
+	if i < 0 {
+		return eventType, 1
+	}
+	version, err := strconv.Atoi(eventType[i+2:])
+	if err != nil || version <= 0 {
+		return eventType, 1
+	}
+	return eventType[:i], version
+}
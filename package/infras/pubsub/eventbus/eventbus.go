@@ -0,0 +1,133 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	"github.com/gianglt2198/federation-go/package/infras/serdes"
+	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+// ContentTypeJSON is the content type of the default serializer
+const ContentTypeJSON = "application/json"
+
+// ErrVersionMismatch is returned to the typed handlers receiving an event of another schema version
+var ErrVersionMismatch = errors.New("event version mismatch")
+
+type (
+	// Bus publishes the events serialized, with their envelope in the headers,
+	// on the pubsub client of the service, NATS or in memory
+	Bus struct {
+		client pubsub.Client
+		queue  pubsub.QueueSubscriber
+
+		source      string
+		serializer  serdes.Serializer
+		contentType string
+		// serializers decode the events by the content type of their envelope
+		serializers map[string]serdes.Serializer
+	}
+
+	Option func(*Bus)
+)
+
+var _ pubsub.EventBus = (*Bus)(nil)
+
+// WithSource sets the source of the published events, the name of the app by default
+func WithSource(source string) Option {
+	return func(b *Bus) {
+		b.source = source
+	}
+}
+
+// WithSerializer encodes the events with the serializer instead of JSON, and decodes the events of its content type
+func WithSerializer(serializer serdes.Serializer, contentType string) Option {
+	return func(b *Bus) {
+		b.serializer = serializer
+		b.contentType = contentType
+		b.serializers[serdes.ContentType(contentType)] = serializer
+	}
+}
+
+// WithDecoder decodes the events of the content type with the serializer, e.g. those of a service publishing in msgpack
+func WithDecoder(serializer serdes.Serializer, contentType string) Option {
+	return func(b *Bus) {
+		b.serializers[serdes.ContentType(contentType)] = serializer
+	}
+}
+
+// New returns a bus publishing JSON events, and decoding the JSON and msgpack ones
+func New(client pubsub.Client, queue pubsub.QueueSubscriber, opts ...Option) *Bus {
+	bus := &Bus{
+		client:      client,
+		queue:       queue,
+		serializer:  serdes.NewJSON(),
+		contentType: ContentTypeJSON,
+		serializers: map[string]serdes.Serializer{
+			serdes.ContentTypeJSON:    serdes.NewJSON(),
+			serdes.ContentTypeMsgPack: serdes.NewMsgPack(),
+		},
+	}
+	for _, opt := range opts {
+		opt(bus)
+	}
+	return bus
+}
+
+// Publish publishes the event, its type is the EventType of the event or its Go type, suffixed by its version
+func (b *Bus) Publish(ctx context.Context, topic string, event interface{}) error {
+	if event == nil {
+		return fmt.Errorf("publish to %s: nil event", topic)
+	}
+
+	name, version := eventType(reflect.TypeOf(event))
+
+	data, err := b.serializer.Encode(event)
+	if err != nil {
+		return fmt.Errorf("encode event %s: %w", name, err)
+	}
+
+	envelope := Envelope{
+		ID:          utils.NewID(24, "evt_"),
+		Source:      b.source,
+		Type:        formatEventType(name, version),
+		Time:        time.Now().UTC(),
+		ContentType: b.contentType,
+	}
+
+	return b.client.Publish(ctx, topic, data, envelope.headers())
+}
+
+// Subscribe passes the encoded events to the handler, the envelope is read with EnvelopeFromContext
+func (b *Bus) Subscribe(ctx context.Context, topic string, handler pubsub.Handler) error {
+	b.client.Subscribe(ctx, topic, handler)
+	return nil
+}
+
+// QueueSubscribe passes each encoded event to one member of the group
+func (b *Bus) QueueSubscribe(ctx context.Context, topic string, group string, handler pubsub.Handler) error {
+	return b.queue.QueueSubscribe(ctx, topic, group, handler)
+}
+
+// Close leaves the pubsub client open, it belongs to the service
+func (b *Bus) Close() error {
+	return nil
+}
+
+// Decode decodes the data of an event by the content type of its envelope,
+// the events without content type with the serializer of the bus
+func (b *Bus) Decode(contentType string, data []byte, event any) error {
+	if contentType == "" {
+		return b.serializer.Decode(data, event)
+	}
+
+	serializer, ok := b.serializers[serdes.ContentType(contentType)]
+	if !ok {
+		return fmt.Errorf("no serializer for content type %q", contentType)
+	}
+	return serializer.Decode(data, event)
+}
@@ -0,0 +1,45 @@
+package eventbus
+
+import (
+	"go.uber.org/fx"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+)
+
+// Module provides the event bus of the service, on top of its pubsub client
+var Module = []fx.Option{
+	fx.Module("eventbus",
+		fx.Provide(
+			NewEventBus,
+			AsEventBus,
+		),
+	),
+}
+
+type EventBusParams struct {
+	fx.In
+
+	AppConfig  config.AppConfig
+	NatsConfig config.NATSConfig
+
+	Client pubsub.Client
+	Queue  pubsub.QueueSubscriber
+}
+
+// NewEventBus creates the event bus of the service, nil when its pubsub is disabled
+func NewEventBus(params EventBusParams) *Bus {
+	if !params.NatsConfig.Enabled {
+		return nil
+	}
+
+	return New(params.Client, params.Queue, WithSource(params.AppConfig.Name))
+}
+
+// AsEventBus provides the bus as a pubsub.EventBus, a nil interface rather than a nil *Bus when it is disabled
+func AsEventBus(bus *Bus) pubsub.EventBus {
+	if bus == nil {
+		return nil
+	}
+	return bus
+}
@@ -0,0 +1,49 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+)
+
+// Publish publishes a typed event on the topic
+func Publish[T any](ctx context.Context, bus *Bus, topic string, event T) error {
+	return bus.Publish(ctx, topic, event)
+}
+
+// Subscribe decodes the events of the topic into T before calling the handler
+func Subscribe[T any](ctx context.Context, bus *Bus, topic string, handler func(context.Context, T) error) error {
+	return bus.Subscribe(ctx, topic, typedHandler(bus, handler))
+}
+
+// QueueSubscribe decodes the events of the topic into T before calling the handler of one member of the group
+func QueueSubscribe[T any](ctx context.Context, bus *Bus, topic string, group string, handler func(context.Context, T) error) error {
+	return bus.QueueSubscribe(ctx, topic, group, typedHandler(bus, handler))
+}
+
+// typedHandler skips the events of other types sharing the topic, and rejects the other versions of T.
+// The events are decoded by the content type of their envelope, those without envelope with the serializer of the bus.
+func typedHandler[T any](bus *Bus, handler func(context.Context, T) error) pubsub.Handler {
+	name, version := eventType(reflect.TypeFor[T]())
+
+	return func(ctx context.Context, msg pubsub.Message) (any, error) {
+		envelope, ok := EnvelopeFromContext(ctx)
+		if ok {
+			if envelope.Name != name {
+				return nil, nil
+			}
+			if envelope.Version != version {
+				return nil, fmt.Errorf("%w: %s is at version %d, the handler expects %d", ErrVersionMismatch, name, envelope.Version, version)
+			}
+		}
+
+		var event T
+		if err := bus.Decode(envelope.ContentType, msg.Data, &event); err != nil {
+			return nil, fmt.Errorf("decode event %s: %w", name, err)
+		}
+
+		return nil, handler(ctx, event)
+	}
+}
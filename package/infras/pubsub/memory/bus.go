@@ -0,0 +1,233 @@
+package psmemory
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+
+	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+// defaultPendingLimit is the number of messages a subscription buffers before being treated as a slow consumer
+const defaultPendingLimit = 65536
+
+// Bus routes the messages between the clients of a process, with the subject semantics of NATS:
+// "*" matches a token, ">" matches the remaining tokens, and a queue group receives each message once.
+// The errors are the nats ones, so that the callers handle both drivers the same way.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[uint64]*subscription
+	nextID uint64
+}
+
+type subscription struct {
+	id      uint64
+	subject string
+	tokens  []string
+	queue   string
+
+	msgs   chan *nats.Msg
+	done   chan struct{}
+	once   sync.Once
+	onDrop func(*nats.Msg)
+}
+
+var defaultBus = NewBus()
+
+// DefaultBus is the bus shared by the clients of the process,
+// so that the services started in the same binary reach each other
+func DefaultBus() *Bus {
+	return defaultBus
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[uint64]*subscription),
+	}
+}
+
+// subscribe delivers the messages of the subject to the handler, one at a time and in order.
+// Messages exceeding the pending limit are dropped and passed to onDrop, like a slow NATS consumer.
+func (b *Bus) subscribe(subject, queue string, handler func(*nats.Msg), onDrop func(*nats.Msg)) (*subscription, error) {
+	tokens, ok := parseSubject(subject, true)
+	if !ok {
+		return nil, nats.ErrBadSubject
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	sub := &subscription{
+		id:      b.nextID,
+		subject: subject,
+		tokens:  tokens,
+		queue:   queue,
+		msgs:    make(chan *nats.Msg, defaultPendingLimit),
+		done:    make(chan struct{}),
+		onDrop:  onDrop,
+	}
+	b.subs[sub.id] = sub
+	b.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case msg := <-sub.msgs:
+				func() {
+					defer utils.RecoverFn()
+					handler(msg)
+				}()
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+func (b *Bus) unsubscribe(sub *subscription) {
+	b.mu.Lock()
+	delete(b.subs, sub.id)
+	b.mu.Unlock()
+
+	sub.once.Do(func() { close(sub.done) })
+}
+
+// publish delivers the message to the matching subscriptions and returns how many received it
+func (b *Bus) publish(msg *nats.Msg) (int, error) {
+	tokens, ok := parseSubject(msg.Subject, false)
+	if !ok {
+		return 0, nats.ErrBadSubject
+	}
+
+	var targets []*subscription
+	groups := make(map[string][]*subscription)
+
+	b.mu.RLock()
+	for _, sub := range b.subs {
+		if !matchSubject(sub.tokens, tokens) {
+			continue
+		}
+		if sub.queue == "" {
+			targets = append(targets, sub)
+			continue
+		}
+		groups[sub.queue] = append(groups[sub.queue], sub)
+	}
+	b.mu.RUnlock()
+
+	for _, members := range groups {
+		targets = append(targets, members[rand.IntN(len(members))])
+	}
+
+	for _, sub := range targets {
+		sub.deliver(copyMsg(msg))
+	}
+
+	return len(targets), nil
+}
+
+// request publishes the message with a reply inbox and waits for the first reply
+func (b *Bus) request(ctx context.Context, msg *nats.Msg, timeout time.Duration) (*nats.Msg, error) {
+	replies := make(chan *nats.Msg, 1)
+	inbox, err := b.subscribe(nats.InboxPrefix+nuid.Next(), "", func(reply *nats.Msg) {
+		select {
+		case replies <- reply:
+		default:
+		}
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer b.unsubscribe(inbox)
+
+	msg.Reply = inbox.subject
+	n, err := b.publish(msg)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nats.ErrNoResponders
+	}
+
+	// without timeout, the request waits for the context
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-expired:
+		return nil, nats.ErrTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *subscription) deliver(msg *nats.Msg) {
+	select {
+	case <-s.done:
+	case s.msgs <- msg:
+	default:
+		if s.onDrop != nil {
+			s.onDrop(msg)
+		}
+	}
+}
+
+// parseSubject splits the subject in tokens, the wildcards are only valid in the subscriptions
+func parseSubject(subject string, wildcards bool) ([]string, bool) {
+	if subject == "" {
+		return nil, false
+	}
+
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return nil, false
+		case token == "*" || token == ">":
+			if !wildcards || (token == ">" && i != len(tokens)-1) {
+				return nil, false
+			}
+		}
+	}
+	return tokens, true
+}
+
+func matchSubject(pattern, subject []string) bool {
+	for i, token := range pattern {
+		if token == ">" {
+			return len(subject) > i
+		}
+		if i >= len(subject) || (token != "*" && token != subject[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(subject)
+}
+
+// copyMsg gives each subscription its own headers, the middlewares write into them
+func copyMsg(msg *nats.Msg) *nats.Msg {
+	cp := &nats.Msg{
+		Subject: msg.Subject,
+		Reply:   msg.Reply,
+		Data:    msg.Data,
+	}
+	if msg.Header != nil {
+		cp.Header = make(nats.Header, len(msg.Header))
+		for k, v := range msg.Header {
+			cp.Header[k] = append([]string(nil), v...)
+		}
+	}
+	return cp
+}
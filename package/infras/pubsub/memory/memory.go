@@ -0,0 +1,336 @@
+package psmemory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/pingcap/errors"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
+	"github.com/gianglt2198/federation-go/package/infras/serdes"
+)
+
+type (
+	// memoryProvider is the in process counterpart of the NATS provider.
+	// The messages are framed and read like the NATS ones, so the handlers cannot tell the drivers apart.
+	memoryProvider struct {
+		cfg      config.NATSConfig
+		bus      *Bus
+		log      *logging.Logger
+		codecs   *serdes.Registry
+		encoding psnats.Encoding
+
+		subscriptions map[string]*subscription
+
+		middlewares []psnats.NatsMiddleware
+
+		mu sync.RWMutex
+	}
+)
+
+var _ pubsub.Client = (*memoryProvider)(nil)
+
+var _ pubsub.QueueSubscriber = (*memoryProvider)(nil)
+
+var _ pubsub.Broker = (*memoryProvider)(nil)
+
+var _ pubsub.RawBroker = (*memoryProvider)(nil)
+
+var _ psnats.DeadLetters = (*memoryProvider)(nil)
+
+// errNoJetStream fails the JetStream uses of the memory driver, which neither redelivers nor keeps the messages
+var errNoJetStream = errors.New("memory pubsub has no jetstream, use the nats driver")
+
+type MemoryParams struct {
+	fx.In
+
+	Log           *logging.Logger
+	Config        config.NATSConfig
+	TracingConfig config.TracingConfig
+
+	// Codecs defaults to the registry of the built-in codecs
+	Codecs *serdes.Registry `optional:"true"`
+	// Bus defaults to the bus of the process
+	Bus *Bus `optional:"true"`
+	// Middlewares of the services, see psnats.AsMiddleware, run after the default ones
+	Middlewares []psnats.NatsMiddleware `group:"nats_middlewares"`
+}
+
+func New(params MemoryParams) (*memoryProvider, error) {
+	if params.Config.JetStream.Enabled {
+		return nil, errors.Wrap(errNoJetStream, "nats.jetstream is enabled")
+	}
+
+	bus := params.Bus
+	if bus == nil {
+		bus = DefaultBus()
+	}
+
+	codecs := params.Codecs
+	if codecs == nil {
+		codecs = serdes.NewRegistry()
+	}
+	encoding := psnats.EncodingOf(params.Config)
+	if err := psnats.ValidateEncoding(codecs, encoding); err != nil {
+		return nil, errors.Wrap(err, "memory pubsub encoding")
+	}
+
+	provider := &memoryProvider{
+		cfg:           params.Config,
+		bus:           bus,
+		log:           params.Log,
+		codecs:        codecs,
+		encoding:      encoding,
+		subscriptions: make(map[string]*subscription),
+	}
+	provider.middlewares = append(psnats.DefaultMiddlewares(params.Log, params.TracingConfig, params.Config.Name, provider.respond), params.Middlewares...)
+
+	return provider, nil
+}
+
+// ReplayDeadLetters fails, the handler errors of the memory driver are only logged and leave no dead letter
+func (p *memoryProvider) ReplayDeadLetters(context.Context, string, int) (int, error) {
+	return 0, errNoJetStream
+}
+
+func (p *memoryProvider) Publish(ctx context.Context, pattern string, data []byte, attrs map[string]string) error {
+	msg, err := p.newMessage(ctx, pattern, data, attrs)
+	if err != nil {
+		return errors.Wrap(err, "send event failed because encode data to json has error")
+	}
+
+	handler := psnats.Chain(ctx, psnats.OperationPublish, func(c context.Context, msg *nats.Msg) error {
+		_, err := p.bus.publish(msg)
+		return err
+	}, p.middlewares...)
+
+	return handler(ctx, msg)
+}
+
+func (p *memoryProvider) Subscribe(ctx context.Context, topic string, handler pubsub.Handler) {
+	if err := p.subscribe(ctx, topic, "", psnats.OperationSubscribe, handler); err != nil {
+		p.log.GetLogger().Error("Failed to subscribe to topic",
+			zap.String("topic", p.subject(topic)),
+			zap.Error(err))
+	}
+}
+
+func (p *memoryProvider) QueueSubscribe(ctx context.Context, topic string, group string, handler pubsub.Handler) error {
+	if err := p.subscribe(ctx, topic, group, psnats.OperationQueueSubscribe, handler); err != nil {
+		return errors.Wrap(err, "failed to subscribe to topic"+topic)
+	}
+	return nil
+}
+
+func (p *memoryProvider) subscribe(ctx context.Context, topic, group, operation string, handler pubsub.Handler) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	subject := p.subject(topic)
+	ctx = psnats.WithSubscription(ctx, subject)
+
+	if _, exists := p.subscriptions[subject]; exists {
+		p.log.GetLogger().Warn("Subscription already exists for topic", zap.String("topic", subject))
+		return nil
+	}
+
+	sub, err := p.bus.subscribe(subject, group, func(msg *nats.Msg) {
+		h := psnats.Chain(ctx, operation, func(c context.Context, msg *nats.Msg) error {
+			return p.handle(c, msg, handler)
+		}, p.middlewares...)
+
+		_ = h(ctx, msg)
+	}, func(msg *nats.Msg) {
+		p.log.GetLogger().Warn("Dropping message of slow consumer", zap.String("topic", msg.Subject))
+	})
+	if err != nil {
+		return err
+	}
+
+	p.subscriptions[subject] = sub
+
+	go func() {
+		<-ctx.Done()
+		_ = p.Unsubscribe(topic)
+	}()
+
+	p.log.GetLogger().Info("Subscribed to topic", zap.String("topic", subject))
+	return nil
+}
+
+func (p *memoryProvider) handle(ctx context.Context, msg *nats.Msg, handler pubsub.Handler) error {
+	data, err := psnats.ReadMsg(p.codecs, msg)
+	if err != nil {
+		// skipped like the NATS provider does, the requests get an ErrorResponse
+		p.log.GetLogger().Error("Error reading message, skipped",
+			zap.String("topic", msg.Subject),
+			zap.Error(err))
+		if msg.Reply != "" {
+			_ = p.respond(msg, psnats.ErrorResponse{Error: psnats.ErrorBody{Code: "bad_request", Message: err.Error()}})
+		}
+		return err
+	}
+
+	ctx = psnats.ApplyHeadersToContext(ctx, msg)
+
+	resp, err := handler(ctx, pubsub.Message{Topic: msg.Subject, Data: data})
+	if err != nil {
+		p.log.GetLogger().Error("Error processing message",
+			zap.String("topic", msg.Subject),
+			zap.Error(err))
+	}
+
+	if resp != nil && msg.Reply != "" {
+		if err := p.respond(msg, resp); err != nil {
+			p.log.GetLogger().Error("Error responding to message",
+				zap.String("topic", msg.Subject),
+				zap.Error(err))
+		}
+	}
+	return err
+}
+
+func (p *memoryProvider) respond(msg *nats.Msg, resp any) error {
+	_, err := p.bus.publish(replyMsg(msg, resp))
+	return err
+}
+
+func (p *memoryProvider) Unsubscribe(topic string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	subject := p.subject(topic)
+
+	sub, exists := p.subscriptions[subject]
+	if !exists {
+		return fmt.Errorf("no subscription found for topic: %s", subject)
+	}
+
+	p.bus.unsubscribe(sub)
+
+	delete(p.subscriptions, subject)
+	p.log.GetLogger().Info("Unsubscribed from topic", zap.String("topic", subject))
+
+	return nil
+}
+
+// Close removes the subscriptions of the client, the bus is left to the other clients of the process
+func (p *memoryProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, sub := range p.subscriptions {
+		p.bus.unsubscribe(sub)
+	}
+
+	p.subscriptions = make(map[string]*subscription)
+	return nil
+}
+
+// Request waits for the first reply up to the timeout, and fails at once when nothing subscribes to the subject
+func (p *memoryProvider) Request(ctx context.Context, pattern string, data any, attrs map[string]string, timeout time.Duration, res any) error {
+	msg, err := p.newMessage(ctx, pattern, data, attrs)
+	if err != nil {
+		return errors.Wrap(err, "new message error")
+	}
+
+	p.log.GetWrappedLogger(ctx).Debug("request to subject", zap.String("subject", msg.Subject), zap.String("type", "request"))
+
+	handler := psnats.Chain(ctx, psnats.OperationRequest, func(c context.Context, msg *nats.Msg) error {
+		resp, err := p.bus.request(c, msg, timeout)
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(resp.Data, res); err != nil {
+			return errors.Wrap(err, "unmarshal response error")
+		}
+		return nil
+	}, p.middlewares...)
+
+	return handler(ctx, msg)
+}
+
+// RequestRaw receives the raw response in a single chunk, the bus has no payload limit
+func (p *memoryProvider) RequestRaw(ctx context.Context, pattern string, data []byte, attrs map[string]string, timeout time.Duration) (*pubsub.Response, error) {
+	msg, err := p.newMessage(ctx, pattern, data, attrs)
+	if err != nil {
+		return nil, errors.Wrap(err, "new message error")
+	}
+	msg.Header.Set(psnats.HeaderChunked, "true")
+
+	var resp *pubsub.Response
+	handler := psnats.Chain(ctx, psnats.OperationRequest, func(c context.Context, msg *nats.Msg) error {
+		reply, err := p.bus.request(c, msg, timeout)
+		if err != nil {
+			return err
+		}
+
+		gatherer := psnats.NewChunkGatherer()
+		if err := gatherer.Add(reply); err != nil {
+			return err
+		}
+		if !gatherer.Done() {
+			return fmt.Errorf("reply of %s is not complete", msg.Subject)
+		}
+
+		resp = gatherer.Response()
+		return nil
+	}, p.middlewares...)
+
+	if err := handler(ctx, msg); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replyMsg encodes the reply like the NATS provider does, raw responses are sent whole
+func replyMsg(msg *nats.Msg, resp any) *nats.Msg {
+	raw, ok := resp.(*pubsub.Response)
+	if !ok && msg.Header.Get(psnats.HeaderChunked) != "" {
+		raw, ok = psnats.NewRawResponse(resp), true
+	}
+
+	switch {
+	case !ok:
+		reply := psnats.NewResponseMsg(resp)
+		reply.Subject = msg.Reply
+		return reply
+	case msg.Header.Get(psnats.HeaderChunked) != "":
+		return psnats.ResponseChunk(msg.Reply, 0, raw, raw.Body, true)
+	default:
+		return &nats.Msg{Subject: msg.Reply, Data: raw.Body}
+	}
+}
+
+func (p *memoryProvider) newMessage(ctx context.Context, pattern string, in any, attrs map[string]string) (*nats.Msg, error) {
+	msg, err := psnats.NewMsg(ctx, p.subject(pattern), p.cfg.Name, p.codecs, p.encoding, in)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range attrs {
+		msg.Header.Set(k, v)
+	}
+
+	return msg, nil
+}
+
+func (p *memoryProvider) subject(pattern string) string {
+	fragments := []string{}
+	if p.cfg.BasePath != "" {
+		fragments = append(fragments, p.cfg.BasePath)
+	}
+	fragments = append(fragments, pattern)
+	return strings.Join(fragments, ".")
+}
@@ -0,0 +1,75 @@
+package psmemory
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
+)
+
+// Module provides the in memory pubsub client as an fx module, with the interfaces of the NATS one
+var Module = []fx.Option{
+	fx.Module("memory_pubsub",
+		fx.Provide(
+			NewMemoryClient,
+			fx.Annotate(
+				func(client *memoryProvider) pubsub.Client { return client },
+				fx.As(new(pubsub.Client)),
+			),
+			fx.Annotate(
+				func(client *memoryProvider) pubsub.QueueSubscriber { return client },
+				fx.As(new(pubsub.QueueSubscriber)),
+			),
+			fx.Annotate(
+				func(client *memoryProvider) pubsub.Broker { return client },
+				fx.As(new(pubsub.Broker)),
+			),
+			fx.Annotate(
+				func(client *memoryProvider) pubsub.QueueClient { return client },
+				fx.As(new(pubsub.QueueClient)),
+			),
+			fx.Annotate(
+				func(client *memoryProvider) pubsub.Publisher { return client },
+				fx.As(new(pubsub.Publisher)),
+			),
+			fx.Annotate(
+				func(client *memoryProvider) psnats.DeadLetters { return client },
+				fx.As(new(psnats.DeadLetters)),
+			),
+		),
+		fx.Invoke(func(lc fx.Lifecycle, client *memoryProvider, logger *logging.Logger) {
+			if client == nil {
+				return
+			}
+
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					logger.GetLogger().Info("Closing in memory pubsub...")
+					return client.Close()
+				},
+			})
+		}),
+	),
+}
+
+// NewMemoryClient creates a new in memory client with dependency injection support
+func NewMemoryClient(params MemoryParams) (*memoryProvider, error) {
+	if !params.Config.Enabled {
+		return nil, nil
+	}
+
+	provider, err := New(params)
+	if err != nil {
+		return nil, err
+	}
+
+	params.Log.GetLogger().Info("In memory pubsub client initialized successfully",
+		zap.String("name", params.Config.Name),
+	)
+
+	return provider, nil
+}
@@ -0,0 +1,334 @@
+package psnats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+)
+
+// Headers added to the dead letters, next to the headers of the original message
+const (
+	HeaderDeadLetterSubject    = "dlq_subject"
+	HeaderDeadLetterError      = "dlq_error"
+	HeaderDeadLetterDeliveries = "dlq_deliveries"
+	HeaderDeadLetterStream     = "dlq_stream"
+	HeaderDeadLetterSequence   = "dlq_sequence"
+	HeaderDeadLetterTime       = "dlq_time"
+)
+
+const (
+	defaultAckWait           = 30 * time.Second
+	defaultMaxDeliver        = 5
+	defaultDeadLetterSubject = "dlq"
+	// defaultInactiveThreshold removes the consumers of the plain subscriptions left behind by a stopped instance
+	defaultInactiveThreshold = time.Minute
+	jetStreamTimeout         = 10 * time.Second
+)
+
+// DeadLetters replays the messages which exhausted their deliveries
+type DeadLetters interface {
+	ReplayDeadLetters(ctx context.Context, topic string, limit int) (int, error)
+}
+
+var _ DeadLetters = (*natsProvider)(nil)
+
+var errUndecodable = errors.New("undecodable message")
+
+func jetStreamConfig(cfg config.NATSJetStreamConfig) config.NATSJetStreamConfig {
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = defaultAckWait
+	}
+	if cfg.MaxDeliver <= 0 {
+		cfg.MaxDeliver = defaultMaxDeliver
+	}
+	if cfg.DeadLetterSubject == "" {
+		cfg.DeadLetterSubject = defaultDeadLetterSubject
+	}
+	return cfg
+}
+
+// setupJetStream creates the stream of the subjects and the one of the dead letters, or updates them to the config
+func (n *natsProvider) setupJetStream() error {
+	js, err := jetstream.New(n.nc)
+	if err != nil {
+		return err
+	}
+	n.js = js
+
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	cfg := n.cfg.JetStream
+	subjects := make([]string, 0, len(cfg.Subjects))
+	for _, subject := range cfg.Subjects {
+		subjects = append(subjects, n.factory.Subject(subject))
+	}
+
+	streams := []jetstream.StreamConfig{
+		{Name: cfg.Stream, Subjects: subjects},
+		{Name: cfg.DeadLetterStream(), Subjects: []string{n.factory.Subject(cfg.DeadLetterSubject) + ".>"}},
+	}
+	for _, stream := range streams {
+		if _, err := js.CreateOrUpdateStream(ctx, stream); err != nil {
+			return fmt.Errorf("create or update stream %s: %w", stream.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// isJetStreamTopic tells whether the topic is consumed from the stream, its wildcards must be covered by the configured subjects
+func (n *natsProvider) isJetStreamTopic(topic string) bool {
+	if n.js == nil {
+		return false
+	}
+
+	tokens := strings.Split(topic, ".")
+	for _, subject := range n.cfg.JetStream.Subjects {
+		if matchSubject(strings.Split(subject, "."), tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+// jetStreamSubscribe consumes the topic with explicit acks: a queue group shares a durable consumer,
+// a plain subscription gets an ephemeral one. Must be called with the lock held.
+func (n *natsProvider) jetStreamSubscribe(ctx context.Context, topic string, group string, operation string, handler pubsub.Handler) error {
+	subject := n.factory.Subject(topic)
+	cfg := n.cfg.JetStream
+
+	consumerConfig := jetstream.ConsumerConfig{
+		FilterSubject: subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxDeliver:    cfg.MaxDeliver,
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+	}
+	if len(cfg.Backoff) > 0 && len(cfg.Backoff) < cfg.MaxDeliver {
+		// the server applies the backoff to the messages whose ack wait expired
+		consumerConfig.BackOff = cfg.Backoff
+	}
+	if group != "" {
+		consumerConfig.Durable = durableName(group, topic)
+	} else {
+		consumerConfig.InactiveThreshold = defaultInactiveThreshold
+	}
+
+	createCtx, cancel := context.WithTimeout(ctx, jetStreamTimeout)
+	defer cancel()
+
+	consumer, err := n.js.CreateOrUpdateConsumer(createCtx, cfg.Stream, consumerConfig)
+	if err != nil {
+		return fmt.Errorf("create consumer of %s: %w", subject, err)
+	}
+
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		n.handleJetStreamMsg(ctx, operation, msg, handler)
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		n.log.GetLogger().Warn("Error consuming stream", zap.String("topic", subject), zap.Error(err))
+	}))
+	if err != nil {
+		return fmt.Errorf("consume %s: %w", subject, err)
+	}
+
+	n.consumers[subject] = consumeCtx
+
+	go func() {
+		<-ctx.Done()
+		_ = n.Unsubscribe(topic)
+	}()
+
+	n.log.GetLogger().Info("Subscribed to stream topic", zap.String("topic", subject), zap.String("stream", cfg.Stream))
+	return nil
+}
+
+func (n *natsProvider) handleJetStreamMsg(ctx context.Context, operation string, msg jetstream.Msg, handler pubsub.Handler) {
+	natsMsg := &nats.Msg{Subject: msg.Subject(), Header: msg.Headers(), Data: msg.Data()}
+
+	h := n.Middleware(ctx, operation, func(c context.Context, m *nats.Msg) (err error) {
+		data, err := n.factory.ReadMessage(m)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUndecodable, err)
+		}
+
+		c = ApplyHeadersToContext(c, m)
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("handler panic: %v", r)
+			}
+		}()
+
+		_, err = handler(c, pubsub.Message{Topic: m.Subject, Data: data})
+		return err
+	}, n.middlewares...)
+
+	n.settle(msg, h(ctx, natsMsg))
+}
+
+// settle acks the handled message, or naks it with the backoff of its delivery.
+// The last failed delivery, and the messages which cannot be decoded, go to the dead-letter subject.
+func (n *natsProvider) settle(msg jetstream.Msg, handleErr error) {
+	log := n.log.GetLogger().With(zap.String("topic", msg.Subject()))
+
+	if handleErr == nil {
+		if err := msg.Ack(); err != nil {
+			log.Error("Error acking message", zap.Error(err))
+		}
+		return
+	}
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		log.Error("Error reading message metadata", zap.Error(err))
+		_ = msg.Nak()
+		return
+	}
+
+	log = log.With(zap.Uint64("deliveries", meta.NumDelivered), zap.Error(handleErr))
+
+	if meta.NumDelivered < uint64(n.cfg.JetStream.MaxDeliver) && !errors.Is(handleErr, errUndecodable) {
+		log.Warn("Error processing message, redelivering")
+		if err := msg.NakWithDelay(n.backoff(meta.NumDelivered)); err != nil {
+			log.Error("Error naking message", zap.Error(err))
+		}
+		return
+	}
+
+	if err := n.deadLetter(msg, meta, handleErr); err != nil {
+		// kept in the stream, the next delivery tries again
+		log.Error("Error sending message to the dead-letter subject", zap.NamedError("dead_letter_error", err))
+		_ = msg.NakWithDelay(n.backoff(meta.NumDelivered))
+		return
+	}
+
+	log.Error("Message sent to the dead-letter subject")
+	if err := msg.Term(); err != nil {
+		log.Error("Error terminating message", zap.Error(err))
+	}
+}
+
+// backoff returns the delay before the next delivery, the last one of the backoff is repeated
+func (n *natsProvider) backoff(delivered uint64) time.Duration {
+	backoff := n.cfg.JetStream.Backoff
+	if len(backoff) == 0 {
+		return 0
+	}
+	i := int(delivered) - 1
+	if i >= len(backoff) {
+		i = len(backoff) - 1
+	}
+	return backoff[max(i, 0)]
+}
+
+func (n *natsProvider) deadLetter(msg jetstream.Msg, meta *jetstream.MsgMetadata, handleErr error) error {
+	out := nats.NewMsg(n.deadLetterSubject(msg.Subject()))
+	for k, v := range msg.Headers() {
+		out.Header[k] = v
+	}
+	out.Header.Set(HeaderDeadLetterSubject, msg.Subject())
+	out.Header.Set(HeaderDeadLetterError, handleErr.Error())
+	out.Header.Set(HeaderDeadLetterDeliveries, strconv.FormatUint(meta.NumDelivered, 10))
+	out.Header.Set(HeaderDeadLetterStream, meta.Stream)
+	out.Header.Set(HeaderDeadLetterSequence, strconv.FormatUint(meta.Sequence.Stream, 10))
+	out.Header.Set(HeaderDeadLetterTime, time.Now().UTC().Format(time.RFC3339Nano))
+	out.Data = msg.Data()
+
+	ctx, cancel := context.WithTimeout(context.Background(), jetStreamTimeout)
+	defer cancel()
+
+	_, err := n.js.PublishMsg(ctx, out)
+	return err
+}
+
+// deadLetterSubject moves the subject, without base path, under the dead-letter prefix
+func (n *natsProvider) deadLetterSubject(subject string) string {
+	if n.cfg.BasePath != "" {
+		subject = strings.TrimPrefix(subject, n.cfg.BasePath+".")
+	}
+	return n.factory.Subject(n.cfg.JetStream.DeadLetterSubject + "." + subject)
+}
+
+// ReplayDeadLetters publishes back up to limit dead letters of the topic, all of them when limit <= 0,
+// to their original subject without the dead-letter headers, and removes them from the dead-letter stream.
+func (n *natsProvider) ReplayDeadLetters(ctx context.Context, topic string, limit int) (int, error) {
+	if n.js == nil {
+		return 0, errors.New("jetstream is not enabled")
+	}
+
+	stream, err := n.js.Stream(ctx, n.cfg.JetStream.DeadLetterStream())
+	if err != nil {
+		return 0, fmt.Errorf("dead-letter stream: %w", err)
+	}
+
+	consumer, err := stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{n.deadLetterSubject(n.factory.Subject(topic))},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("dead-letter consumer: %w", err)
+	}
+
+	replayed := 0
+	for limit <= 0 || replayed < limit {
+		msg, err := consumer.Next(jetstream.FetchMaxWait(time.Second))
+		if errors.Is(err, nats.ErrTimeout) {
+			break
+		}
+		if err != nil {
+			return replayed, err
+		}
+
+		out := nats.NewMsg(msg.Headers().Get(HeaderDeadLetterSubject))
+		for k, v := range msg.Headers() {
+			if !strings.HasPrefix(k, "dlq_") {
+				out.Header[k] = v
+			}
+		}
+		out.Data = msg.Data()
+
+		if _, err := n.js.PublishMsg(ctx, out); err != nil {
+			return replayed, fmt.Errorf("replay to %s: %w", out.Subject, err)
+		}
+
+		meta, err := msg.Metadata()
+		if err != nil {
+			return replayed, err
+		}
+		if err := stream.DeleteMsg(ctx, meta.Sequence.Stream); err != nil && !errors.Is(err, jetstream.ErrMsgNotFound) {
+			return replayed, fmt.Errorf("delete dead letter %d: %w", meta.Sequence.Stream, err)
+		}
+
+		replayed++
+	}
+
+	n.log.GetWrappedLogger(ctx).Info("Replayed dead letters", zap.String("topic", topic), zap.Int("count", replayed))
+	return replayed, nil
+}
+
+// durableName names the consumer of a group for a topic, the names cannot hold the subject separators
+func durableName(group, topic string) string {
+	return strings.NewReplacer(".", "_", "*", "all", ">", "rest").Replace(group + "_" + topic)
+}
+
+func matchSubject(pattern, subject []string) bool {
+	for i, token := range pattern {
+		if token == ">" {
+			return len(subject) > i
+		}
+		if i >= len(subject) || (token != "*" && token != subject[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(subject)
+}
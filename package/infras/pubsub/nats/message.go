@@ -2,13 +2,14 @@ package psnats
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
 	nats "github.com/nats-io/nats.go"
-	"go.opentelemetry.io/otel/propagation"
 
-	"github.com/gianglt2198/federation-go/package/infras/monitoring/tracing"
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natsctx"
 	"github.com/gianglt2198/federation-go/package/infras/serdes"
 	"github.com/gianglt2198/federation-go/package/utils"
 )
@@ -19,55 +20,69 @@ type MessageFactory interface {
 	ReadMessage(msg *nats.Msg) ([]byte, error)
 }
 
-type messageFactory struct {
-	provider    *natsProvider
-	serdesModel serdes.Serializer
+// Headers declaring how the payload of a message is encoded, so that the consumers decode it whatever its publisher.
+// The messages without them are msgpack framed, as the services published them before the headers.
+const (
+	HeaderContentType     = "content-type"
+	HeaderContentEncoding = "content-encoding"
+)
+
+// Encoding is the content type and the optional compression of the published messages
+type Encoding struct {
+	ContentType     string
+	ContentEncoding string
 }
 
-func NewMessageFactory(provider *natsProvider, serdesModel serdes.Serializer) MessageFactory {
-	return &messageFactory{
-		provider:    provider,
-		serdesModel: serdesModel,
+// EncodingOf returns the encoding configured for the publisher, msgpack when empty
+func EncodingOf(cfg config.NATSConfig) Encoding {
+	encoding := Encoding{
+		ContentType:     serdes.ContentType(cfg.ContentType),
+		ContentEncoding: cfg.ContentEncoding,
+	}
+	if encoding.ContentType == "" {
+		encoding.ContentType = serdes.ContentTypeMsgPack
 	}
+	return encoding
 }
 
-func (f *messageFactory) NewMessage(ctx context.Context, pattern string, in any, attrs map[string]string) (*nats.Msg, error) {
-	subject := f.Subject(pattern)
-	msg := nats.NewMsg(subject)
-	f.setDefaultHeaders(ctx, msg)
-
-	for k, v := range attrs {
-		msg.Header.Set(k, attrs[v])
+// ValidateEncoding checks the configured encoding at startup, rather than at the first message.
+// Protobuf encodes proto messages only, so it cannot be the content type of every published message.
+func ValidateEncoding(codecs *serdes.Registry, encoding Encoding) error {
+	if encoding.ContentType == serdes.ContentTypeProtobuf {
+		return fmt.Errorf("content type %s encodes proto messages only, use json or msgpack", encoding.ContentType)
 	}
+	return codecs.Supports(encoding.ContentType, encoding.ContentEncoding)
+}
 
-	var (
-		data []byte
-		err  error
-	)
+type messageFactory struct {
+	provider *natsProvider
+	codecs   *serdes.Registry
+	encoding Encoding
+}
+
+func NewMessageFactory(provider *natsProvider, codecs *serdes.Registry) MessageFactory {
+	return &messageFactory{
+		provider: provider,
+		codecs:   codecs,
+		encoding: EncodingOf(provider.cfg),
+	}
+}
 
-	data, err = f.serdesModel.Encode(in)
+func (f *messageFactory) NewMessage(ctx context.Context, pattern string, in any, attrs map[string]string) (*nats.Msg, error) {
+	msg, err := NewMsg(ctx, f.Subject(pattern), f.provider.cfg.Name, f.codecs, f.encoding, in)
 	if err != nil {
 		return nil, err
 	}
 
-	msg.Data = data
+	for k, v := range attrs {
+		msg.Header.Set(k, v)
+	}
+
 	return msg, nil
 }
 
 func (f *messageFactory) ReadMessage(msg *nats.Msg) ([]byte, error) {
-	var (
-		data []byte
-		err  error
-	)
-
-	in := msg.Data
-
-	err = f.serdesModel.Decode(in, &data)
-	if err != nil {
-		return nil, err
-	}
-
-	return data, nil
+	return ReadMsg(f.codecs, msg)
 }
 
 func (f *messageFactory) Subject(pattern string) string {
@@ -79,22 +94,40 @@ func (f *messageFactory) Subject(pattern string) string {
 	return strings.Join(fragments, ".")
 }
 
-func (f *messageFactory) setDefaultHeaders(ctx context.Context, msg *nats.Msg) {
-	utils.ApplyTraceIDWithContext(ctx)
-	utils.ApplySpanIDWithContext(ctx)
-	utils.ApplyRequestIDWithContext(ctx)
-	userID := utils.GetUserIDFromCtx(ctx)
-	if userID == "" {
-		userID = "system"
-	}
-	msg.Header.Set("user_id", userID)
-	msg.Header.Set("from", f.provider.cfg.Name)
-	msg.Header.Set("start_time", time.Now().UTC().Format(time.RFC3339Nano))
+// NewMsg frames the data the way the services exchange it: encoded, with the default and the content headers.
+// Clients without a provider, like the gateway event providers, use it to talk to the services.
+func NewMsg(ctx context.Context, subject, from string, codecs *serdes.Registry, encoding Encoding, in any) (*nats.Msg, error) {
+	msg := nats.NewMsg(subject)
+	setDefaultHeaders(ctx, msg, from)
 
-	carrier := propagation.MapCarrier{}
-	tracing.Propagation().Inject(ctx, carrier)
+	data, err := codecs.Encode(encoding.ContentType, encoding.ContentEncoding, in)
+	if err != nil {
+		return nil, err
+	}
 
-	for key, val := range carrier {
-		msg.Header.Set(key, val)
+	msg.Header.Set(HeaderContentType, serdes.ContentType(encoding.ContentType))
+	if encoding.ContentEncoding != "" {
+		msg.Header.Set(HeaderContentEncoding, encoding.ContentEncoding)
 	}
+
+	msg.Data = data
+	return msg, nil
+}
+
+// ReadMsg returns the payload of a message framed by NewMsg, decoded as its headers declare it.
+// The messages without content type are decoded with the default one of the registry.
+func ReadMsg(codecs *serdes.Registry, msg *nats.Msg) ([]byte, error) {
+	return codecs.Decode(msg.Header.Get(HeaderContentType), msg.Header.Get(HeaderContentEncoding), msg.Data)
+}
+
+// setDefaultHeaders maps the context to the headers, the messages without request get a new one.
+// The user header is only set from an authenticated user of the context.
+func setDefaultHeaders(ctx context.Context, msg *nats.Msg, from string) {
+	ctx, _ = utils.ApplyTraceIDWithContext(ctx)
+	ctx, _ = utils.ApplySpanIDWithContext(ctx)
+	ctx, _ = utils.ApplyRequestIDWithContext(ctx)
+
+	natsctx.Inject(ctx, msg.Header)
+	msg.Header.Set("from", from)
+	msg.Header.Set(string(HeaderStartTime), time.Now().UTC().Format(time.RFC3339Nano))
 }
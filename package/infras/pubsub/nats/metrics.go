@@ -0,0 +1,125 @@
+package psnats
+
+import (
+	"context"
+	"log"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/tracing"
+)
+
+const (
+	metricNatsMessagesCounter  = "nats_messages_total"
+	metricNatsMessageDuration  = "nats_message_duration_milliseconds"
+	metricNatsMessageSize      = "nats_message_size_bytes"
+	metricNatsActiveOperations = "nats_active_operations_total"
+)
+
+type natsMetric struct {
+	service string
+
+	messagesCounter  metric.Int64Counter
+	activeOperations metric.Int64UpDownCounter
+	messageDuration  metric.Int64Histogram
+	messageSize      metric.Int64Histogram
+}
+
+func newNatsMetric(service string) *natsMetric {
+	nm := &natsMetric{service: service}
+
+	m := tracing.Meter(service)
+
+	var err error
+
+	nm.messagesCounter, err = m.Int64Counter(
+		metricNatsMessagesCounter,
+		metric.WithDescription("Total number of NATS messages published, requested and handled."),
+		metric.WithUnit("{messages}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats messages counter failed: %v", err)
+	}
+
+	nm.activeOperations, err = m.Int64UpDownCounter(
+		metricNatsActiveOperations,
+		metric.WithDescription("Number of in-flight NATS operations."),
+		metric.WithUnit("{operations}"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats active operations counter failed: %v", err)
+	}
+
+	nm.messageDuration, err = m.Int64Histogram(
+		metricNatsMessageDuration,
+		metric.WithDescription("The duration of a NATS operation, the handling of the messages for the subscriptions."),
+		metric.WithUnit("ms"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats message duration failed: %v", err)
+	}
+
+	nm.messageSize, err = m.Int64Histogram(
+		metricNatsMessageSize,
+		metric.WithDescription("The size of a NATS message payload."),
+		metric.WithUnit("by"),
+	)
+	if err != nil {
+		log.Fatalf("creating meter nats message size failed: %v", err)
+	}
+
+	return nm
+}
+
+type subscriptionKey struct{}
+
+// WithSubscription names the subscription whose messages the chain built with the context handles
+func WithSubscription(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subscriptionKey{}, subject)
+}
+
+// SubscriptionOf returns the subscription of the chain, empty for the producers
+func SubscriptionOf(ctx context.Context) string {
+	subject, _ := ctx.Value(subscriptionKey{}).(string)
+	return subject
+}
+
+// MetricMiddleware counts the messages and records their latency, per subscription, operation and status.
+// The subjects of the messages are left out of the labels, they may carry ids: the consumers are labelled
+// with the subject pattern of their subscription, the producers with none.
+func MetricMiddleware(service string) NatsMiddleware {
+	nm := newNatsMetric(service)
+
+	return func(chainCtx context.Context, operation string, handler func(context.Context, *nats.Msg) error) func(context.Context, *nats.Msg) error {
+		attrs := []attribute.KeyValue{
+			attribute.String("nats.subscription", SubscriptionOf(chainCtx)),
+			attribute.String("nats.operation", operation),
+			attribute.String("service.name", nm.service),
+		}
+
+		return func(ctx context.Context, msg *nats.Msg) error {
+			start := time.Now()
+
+			active := metric.WithAttributeSet(attribute.NewSet(attrs...))
+			nm.activeOperations.Add(ctx, 1, active)
+			nm.messageSize.Record(ctx, int64(len(msg.Data)), active)
+
+			err := handler(ctx, msg)
+
+			status := "ok"
+			if err != nil {
+				status = "error"
+			}
+			done := metric.WithAttributeSet(attribute.NewSet(append(attrs[:len(attrs):len(attrs)], attribute.String("status", status))...))
+
+			nm.activeOperations.Add(ctx, -1, active)
+			nm.messagesCounter.Add(ctx, 1, done)
+			nm.messageDuration.Record(ctx, time.Since(start).Milliseconds(), done)
+
+			return err
+		}
+	}
+}
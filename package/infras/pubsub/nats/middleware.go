@@ -2,13 +2,80 @@ package psnats
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
 
 	nats "github.com/nats-io/nats.go"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	tcnats "github.com/gianglt2198/federation-go/package/infras/monitoring/tracing/nats"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natsctx"
 )
 
 type NatsMiddleware func(context.Context, string, func(context.Context, *nats.Msg) error) func(context.Context, *nats.Msg) error
 
+// MiddlewareGroup is the fx group of the middlewares added by the services after the default ones
+const MiddlewareGroup = "nats_middlewares"
+
+// HeaderDeadline is the time, RFC3339Nano, after which the handler of the message gives up
+const HeaderDeadline = "deadline"
+
+// Operations of the chains, the subscriptions handle the messages the others send
+const (
+	OperationPublish        = "publish"
+	OperationRequest        = "request"
+	OperationSubscribe      = "subscribe"
+	OperationQueueSubscribe = "queue_subscribe"
+)
+
+// ErrorResponse is the reply of the handlers which panicked
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Responder replies to a request, like the provider does with the response of its handler
+type Responder func(msg *nats.Msg, resp any) error
+
+// AsMiddleware annotates a middleware constructor so that it is registered in the NATS middlewares group
+func AsMiddleware(constructor any) any {
+	return fx.Annotate(
+		constructor,
+		fx.ResultTags(fmt.Sprintf(`group:"%s"`, MiddlewareGroup)),
+	)
+}
+
+// DefaultMiddlewares returns the standard chain, outermost first: tracing when enabled, metrics, recovery,
+// identity and deadline. The middlewares of the services run after them.
+func DefaultMiddlewares(log *logging.Logger, tracingCfg config.TracingConfig, service string, respond Responder) []NatsMiddleware {
+	middlewares := []NatsMiddleware{}
+
+	if tracingCfg.Enabled {
+		middlewares = append(middlewares, tcnats.OperationMiddleware)
+	}
+
+	return append(middlewares,
+		MetricMiddleware(service),
+		RecoveryMiddleware(log, respond),
+		IdentityMiddleware,
+		DeadlineMiddleware,
+	)
+}
+
 func (n *natsProvider) Middleware(ctx context.Context, operation string, handler func(context.Context, *nats.Msg) error, ms ...NatsMiddleware) func(context.Context, *nats.Msg) error {
+	return Chain(ctx, operation, handler, ms...)
+}
+
+// Chain wraps the handler of the operation with the middlewares
+func Chain(ctx context.Context, operation string, handler func(context.Context, *nats.Msg) error, ms ...NatsMiddleware) func(context.Context, *nats.Msg) error {
 	h := handler
 	// middleware are applied in reverse; this makes the first middleware
 	// in the slice the outermost i.e. first to enter, last to exit
@@ -20,3 +87,94 @@ func (n *natsProvider) Middleware(ctx context.Context, operation string, handler
 
 	return h
 }
+
+func isConsumer(operation string) bool {
+	return operation == OperationSubscribe || operation == OperationQueueSubscribe
+}
+
+// RecoveryMiddleware turns the panics of the handlers into errors,
+// and answers the requests with an ErrorResponse instead of letting them time out
+func RecoveryMiddleware(log *logging.Logger, respond Responder) NatsMiddleware {
+	return func(_ context.Context, operation string, handler func(context.Context, *nats.Msg) error) func(context.Context, *nats.Msg) error {
+		return func(ctx context.Context, msg *nats.Msg) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+
+				err = fmt.Errorf("%s handler panic: %v", operation, r)
+				log.GetWrappedLogger(ctx).Error("Recovered from handler panic",
+					zap.String("subject", msg.Subject),
+					zap.String("operation", operation),
+					zap.Any("panic", r),
+					zap.ByteString("stack", debug.Stack()))
+
+				if !isConsumer(operation) || msg.Reply == "" || respond == nil {
+					return
+				}
+
+				resp := ErrorResponse{Error: ErrorBody{Code: "internal_error", Message: "internal server error"}}
+				if rerr := respond(msg, resp); rerr != nil {
+					log.GetWrappedLogger(ctx).Error("Error responding to message", zap.String("subject", msg.Subject), zap.Error(rerr))
+				}
+			}()
+
+			return handler(ctx, msg)
+		}
+	}
+}
+
+// IdentityMiddleware sends the request, user, tenant, trace and baggage of the context as headers,
+// and restores them in the context of the handlers, see natsctx
+func IdentityMiddleware(_ context.Context, operation string, handler func(context.Context, *nats.Msg) error) func(context.Context, *nats.Msg) error {
+	return func(ctx context.Context, msg *nats.Msg) error {
+		if msg.Header == nil {
+			msg.Header = nats.Header{}
+		}
+
+		if isConsumer(operation) {
+			ctx = natsctx.Extract(ctx, msg.Header)
+		} else {
+			natsctx.Inject(ctx, msg.Header)
+		}
+
+		return handler(ctx, msg)
+	}
+}
+
+// DeadlineMiddleware sends the deadline of the context as header of the requests, and bounds the context of the
+// handlers by it. The messages received past their deadline are not handled. The published messages carry none,
+// the deadline of the publisher does not bound their consumers.
+func DeadlineMiddleware(_ context.Context, operation string, handler func(context.Context, *nats.Msg) error) func(context.Context, *nats.Msg) error {
+	return func(ctx context.Context, msg *nats.Msg) error {
+		if msg.Header == nil {
+			msg.Header = nats.Header{}
+		}
+
+		if !isConsumer(operation) {
+			if deadline, ok := ctx.Deadline(); ok && operation == OperationRequest && msg.Header.Get(HeaderDeadline) == "" {
+				msg.Header.Set(HeaderDeadline, deadline.UTC().Format(time.RFC3339Nano))
+			}
+			return handler(ctx, msg)
+		}
+
+		value := msg.Header.Get(HeaderDeadline)
+		if value == "" {
+			return handler(ctx, msg)
+		}
+
+		deadline, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return handler(ctx, msg)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("message of %s received after its deadline %s: %w", msg.Subject, value, context.DeadlineExceeded)
+		}
+
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+
+		return handler(ctx, msg)
+	}
+}
@@ -35,6 +35,10 @@ var Module = []fx.Option{
 				func(client *natsProvider) pubsub.Publisher { return client },
 				fx.As(new(pubsub.Publisher)),
 			),
+			fx.Annotate(
+				func(client *natsProvider) DeadLetters { return client },
+				fx.As(new(DeadLetters)),
+			),
 		),
 		fx.Invoke(func(lc fx.Lifecycle, client *natsProvider, logger *logging.Logger) {
 			if client == nil {
@@ -52,18 +56,21 @@ var Module = []fx.Option{
 }
 
 // NewNATSClient creates a new NATS client with dependency injection support
-func NewNATSClient(params NatsParams) *natsProvider {
-	if params.Config.Endpoint == "" {
-		return nil
+func NewNATSClient(params NatsParams) (*natsProvider, error) {
+	if params.Config.URLs() == "" {
+		return nil, nil
 	}
 
-	provider := New(params)
+	provider, err := New(params)
+	if err != nil || provider == nil {
+		return nil, err
+	}
 
 	// Add health check
 	params.Log.GetLogger().Info("NATS client initialized successfully",
-		zap.String("endpoint", params.Config.Endpoint),
+		zap.String("endpoint", params.Config.URLs()),
 		zap.String("name", params.Config.Name),
 	)
 
-	return provider
+	return provider, nil
 }
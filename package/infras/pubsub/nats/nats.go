@@ -3,32 +3,46 @@ package psnats
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"sync"
 	"time"
 
 	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/pingcap/errors"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 
 	"github.com/gianglt2198/federation-go/package/config"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
-	tcnats "github.com/gianglt2198/federation-go/package/infras/monitoring/tracing/nats"
 	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natsconn"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natsctx"
 	"github.com/gianglt2198/federation-go/package/infras/serdes"
-	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+// The defaults of the envDefault tags, which the configuration loader does not apply
+const (
+	defaultMaxReconnects   = 500
+	defaultPingInterval    = 10 * time.Second
+	defaultConnectAttempts = 10
+	defaultConnectBackoff  = time.Second
+	maxConnectBackoff      = 30 * time.Second
+	defaultDrainTimeout    = 30 * time.Second
 )
 
 type (
 	natsProvider struct {
 		cfg     config.NATSConfig
 		nc      *nats.Conn
+		js      jetstream.JetStream
 		log     *logging.Logger
+		closed  chan struct{}
 		factory MessageFactory
 
 		subscriptions map[string]*nats.Subscription
-		chans         map[string]chan *nats.Msg
+		consumers     map[string]jetstream.ConsumeContext
 
 		middlewares []NatsMiddleware
 
@@ -42,6 +56,8 @@ var _ pubsub.QueueSubscriber = (*natsProvider)(nil)
 
 var _ pubsub.Broker = (*natsProvider)(nil)
 
+var _ pubsub.RawBroker = (*natsProvider)(nil)
+
 type NatsParams struct {
 	fx.In
 
@@ -49,23 +65,54 @@ type NatsParams struct {
 	Config        config.NATSConfig
 	TracingConfig config.TracingConfig
 
-	SeqModel serdes.Serializer
+	// Codecs defaults to the registry of the built-in codecs
+	Codecs *serdes.Registry `optional:"true"`
+	// Middlewares of the services, see AsMiddleware, run after the default ones
+	Middlewares []NatsMiddleware `group:"nats_middlewares"`
 }
 
-func New(params NatsParams) *natsProvider {
-	provider := connect(params.Log, params.Config, params.TracingConfig)
-	provider.factory = NewMessageFactory(provider, params.SeqModel)
-	return provider
+func New(params NatsParams) (*natsProvider, error) {
+	provider, err := connect(params.Log, params.Config)
+	if err != nil || provider == nil {
+		return nil, err
+	}
+	codecs := params.Codecs
+	if codecs == nil {
+		codecs = serdes.NewRegistry()
+	}
+	if err := ValidateEncoding(codecs, EncodingOf(params.Config)); err != nil {
+		provider.nc.Close()
+		return nil, errors.Wrap(err, "nats encoding")
+	}
+	provider.factory = NewMessageFactory(provider, codecs)
+	provider.middlewares = append(DefaultMiddlewares(params.Log, params.TracingConfig, params.Config.Name, provider.respond), params.Middlewares...)
+
+	if params.Config.JetStream.Enabled {
+		if err := provider.setupJetStream(); err != nil {
+			provider.nc.Close()
+			return nil, errors.Wrap(err, "jetstream setup error")
+		}
+	}
+
+	return provider, nil
 }
 
-func connect(log *logging.Logger, cfg config.NATSConfig, tracingCfg config.TracingConfig) *natsProvider {
+func connect(log *logging.Logger, cfg config.NATSConfig) (*natsProvider, error) {
 	if !cfg.Enabled {
-		return nil
+		return nil, nil
 	}
 
+	cfg = withDefaults(cfg)
+
+	closed := make(chan struct{})
+
 	options := []nats.Option{
 		nats.Name(cfg.Name),
 		nats.PingInterval(cfg.PingInterval),
+		nats.DrainTimeout(cfg.DrainTimeout),
+		nats.ClosedHandler(func(c *nats.Conn) {
+			close(closed)
+		}),
 	}
 
 	if cfg.AllowReconnect {
@@ -74,31 +121,81 @@ func connect(log *logging.Logger, cfg config.NATSConfig, tracingCfg config.Traci
 			log.GetLogger().Info("Connected to nats successfully")
 		}))
 		options = append(options, nats.ReconnectHandler(func(c *nats.Conn) {
-			log.GetLogger().Info("Reconnected to nats server")
+			log.GetLogger().Info("Reconnected to nats server", zap.String("url", c.ConnectedUrlRedacted()))
 		}))
 		options = append(options, nats.DisconnectErrHandler(func(c *nats.Conn, err error) {
 			log.GetLogger().Warn("Disconnected from nats server", zap.Error(err))
 		}))
+	} else {
+		options = append(options, nats.NoReconnect())
 	}
 
-	nc, err := nats.Connect(cfg.Endpoint, options...)
+	connOptions, err := natsconn.Options(cfg.Authentication, cfg.TLS)
 	if err != nil {
-		log.GetLogger().Panic("Connection error %s", zap.Error(err))
+		return nil, errors.Wrap(err, "nats connection options")
 	}
+	options = append(options, connOptions...)
 
-	middlewares := []NatsMiddleware{}
-
-	if tracingCfg.Enabled {
-		middlewares = append(middlewares, tcnats.OperationMiddleware)
+	nc, err := connectWithRetry(log, cfg, options)
+	if err != nil {
+		return nil, err
 	}
 
+	cfg.JetStream = jetStreamConfig(cfg.JetStream)
+
 	return &natsProvider{
 		cfg:           cfg,
 		nc:            nc,
 		log:           log,
+		closed:        closed,
 		subscriptions: make(map[string]*nats.Subscription),
-		chans:         make(map[string]chan *nats.Msg),
-		middlewares:   middlewares,
+		consumers:     make(map[string]jetstream.ConsumeContext),
+	}, nil
+}
+
+// withDefaults fills the settings left empty by the configuration
+func withDefaults(cfg config.NATSConfig) config.NATSConfig {
+	if cfg.MaxReconnects == 0 {
+		cfg.MaxReconnects = defaultMaxReconnects
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = defaultPingInterval
+	}
+	if cfg.ConnectAttempts == 0 {
+		cfg.ConnectAttempts = defaultConnectAttempts
+	}
+	if cfg.ConnectBackoff <= 0 {
+		cfg.ConnectBackoff = defaultConnectBackoff
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = defaultDrainTimeout
+	}
+	return cfg
+}
+
+// connectWithRetry retries the first connection with an exponential backoff,
+// the servers refusing the credentials are not retried
+func connectWithRetry(log *logging.Logger, cfg config.NATSConfig, options []nats.Option) (*nats.Conn, error) {
+	delay := cfg.ConnectBackoff
+
+	for attempt := 1; ; attempt++ {
+		nc, err := nats.Connect(cfg.URLs(), options...)
+		if err == nil {
+			log.GetLogger().Info("Connected to nats server", zap.String("url", nc.ConnectedUrlRedacted()))
+			return nc, nil
+		}
+
+		if stderrors.Is(err, nats.ErrAuthorization) || (cfg.ConnectAttempts > 0 && attempt >= cfg.ConnectAttempts) {
+			return nil, fmt.Errorf("connect to nats after %d attempts: %w", attempt, err)
+		}
+
+		log.GetLogger().Warn("Connection to nats failed, retrying",
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		time.Sleep(delay)
+		delay = min(delay*2, maxConnectBackoff)
 	}
 }
 
@@ -108,7 +205,7 @@ func (n *natsProvider) Publish(ctx context.Context, pattern string, data []byte,
 		return errors.Wrap(err, "send event failed because encode data to json has error")
 	}
 
-	handler := n.Middleware(ctx, "publish", func(c context.Context, msg *nats.Msg) error {
+	handler := n.Middleware(ctx, OperationPublish, func(c context.Context, msg *nats.Msg) error {
 		return n.nc.PublishMsg(msg)
 	}, n.middlewares...)
 
@@ -120,21 +217,35 @@ func (n *natsProvider) Subscribe(ctx context.Context, topic string, handler pubs
 	defer n.mu.Unlock()
 
 	subject := n.factory.Subject(topic)
+	ctx = WithSubscription(ctx, subject)
 
-	if _, exists := n.subscriptions[subject]; exists {
+	if n.subscribed(subject) {
 		n.log.GetLogger().Warn("Subscription already exists for topic", zap.String("topic", subject))
 		return
 	}
 
+	if n.isJetStreamTopic(topic) {
+		if err := n.jetStreamSubscribe(ctx, topic, "", OperationSubscribe, handler); err != nil {
+			n.log.GetLogger().Error("Failed to subscribe to topic",
+				zap.String("topic", subject),
+				zap.Error(err))
+		}
+		return
+	}
+
 	sub, err := n.nc.Subscribe(subject, func(msg *nats.Msg) {
-		handler := n.Middleware(ctx, "subscribe", func(c context.Context, msg *nats.Msg) error {
+		msg, err := n.gatherRequest(msg)
+		if err != nil {
+			n.log.GetLogger().Error("Error gathering request chunks", zap.String("topic", subject), zap.Error(err))
+			return
+		}
+
+		handler := n.Middleware(ctx, OperationSubscribe, func(c context.Context, msg *nats.Msg) error {
 			data, err := n.factory.ReadMessage(msg)
 			if err != nil {
-				n.log.GetLogger().Error("Error reading message",
-					zap.String("topic", msg.Subject),
-					zap.Error(err))
+				return n.reject(msg, err)
 			}
-			c = applyHeadersToContext(c, msg)
+			c = ApplyHeadersToContext(c, msg)
 
 			resp, err := handler(c, pubsub.Message{Topic: msg.Subject, Data: data})
 			if err != nil {
@@ -143,13 +254,13 @@ func (n *natsProvider) Subscribe(ctx context.Context, topic string, handler pubs
 					zap.Error(err))
 			}
 			if resp != nil {
-				if err := msg.RespondMsg(natsResponse(resp)); err != nil {
+				if err := n.respond(msg, resp); err != nil {
 					n.log.GetLogger().Error("Error responding to message",
 						zap.String("topic", msg.Subject),
 						zap.Error(err))
 				}
 			}
-			return nil
+			return err
 		}, n.middlewares...)
 
 		_ = handler(ctx, msg)
@@ -177,58 +288,60 @@ func (n *natsProvider) QueueSubscribe(ctx context.Context, topic string, group s
 	defer n.mu.Unlock()
 
 	subject := n.factory.Subject(topic)
+	ctx = WithSubscription(ctx, subject)
 
-	if _, exists := n.subscriptions[subject]; exists {
+	if n.subscribed(subject) {
 		n.log.GetLogger().Warn("Subscription already exists for topic", zap.String("topic", subject))
 		return nil
 	}
 
-	ch := make(chan *nats.Msg)
+	if n.isJetStreamTopic(topic) {
+		return n.jetStreamSubscribe(ctx, topic, group, OperationQueueSubscribe, handler)
+	}
 
-	sub, err := n.nc.ChanQueueSubscribe(subject, group, ch)
+	// the callback subscription buffers the messages while the handler runs, and lets Drain wait for it
+	sub, err := n.nc.QueueSubscribe(subject, group, func(msg *nats.Msg) {
+		msg, err := n.gatherRequest(msg)
+		if err != nil {
+			n.log.GetLogger().Error("Error gathering request chunks", zap.String("topic", subject), zap.Error(err))
+			return
+		}
 
-	if err != nil {
-		n.log.GetLogger().Error("Failed to subscribe to topic",
-			zap.String("topic", subject),
-			zap.Error(err))
-		return errors.Wrap(err, "failed to subscribe to topic"+topic)
-	}
+		handler := n.Middleware(ctx, OperationQueueSubscribe, func(c context.Context, msg *nats.Msg) error {
+			data, err := n.factory.ReadMessage(msg)
+			if err != nil {
+				return n.reject(msg, err)
+			}
 
-	n.subscriptions[subject] = sub
-	n.chans[subject] = ch
+			c = ApplyHeadersToContext(c, msg)
 
-	go func() {
-		defer utils.RecoverFn()
-		for msg := range ch {
-			handler := n.Middleware(ctx, "queue_subscribe", func(c context.Context, msg *nats.Msg) error {
-				data, err := n.factory.ReadMessage(msg)
-				if err != nil {
-					n.log.GetLogger().Error("Error reading message",
+			resp, err := handler(c, pubsub.Message{Topic: msg.Subject, Data: data})
+			if err != nil {
+				n.log.GetLogger().Error("Error processing message",
+					zap.String("topic", msg.Subject),
+					zap.Error(err))
+			}
+			if resp != nil {
+				if err := n.respond(msg, resp); err != nil {
+					n.log.GetLogger().Error("Error responding to message",
 						zap.String("topic", msg.Subject),
 						zap.Error(err))
 				}
+			}
+			return err
+		}, n.middlewares...)
 
-				c = applyHeadersToContext(c, msg)
+		_ = handler(ctx, msg)
+	})
 
-				resp, err := handler(c, pubsub.Message{Topic: msg.Subject, Data: data})
-				if err != nil {
-					n.log.GetLogger().Error("Error processing message",
-						zap.String("topic", msg.Subject),
-						zap.Error(err))
-				}
-				if resp != nil {
-					if err := msg.RespondMsg(natsResponse(resp)); err != nil {
-						n.log.GetLogger().Error("Error responding to message",
-							zap.String("topic", msg.Subject),
-							zap.Error(err))
-					}
-				}
-				return nil
-			}, n.middlewares...)
+	if err != nil {
+		n.log.GetLogger().Error("Failed to subscribe to topic",
+			zap.String("topic", subject),
+			zap.Error(err))
+		return errors.Wrap(err, "failed to subscribe to topic"+topic)
+	}
 
-			_ = handler(ctx, msg)
-		}
-	}()
+	n.subscriptions[subject] = sub
 
 	go func() {
 		<-ctx.Done()
@@ -245,9 +358,11 @@ func (n *natsProvider) Unsubscribe(topic string) error {
 
 	subject := n.factory.Subject(topic)
 
-	if v, ok := n.chans[subject]; ok {
-		close(v)
-		delete(n.chans, subject)
+	if consumeCtx, ok := n.consumers[subject]; ok {
+		consumeCtx.Stop()
+		delete(n.consumers, subject)
+		n.log.GetLogger().Info("Unsubscribed from stream topic", zap.String("topic", subject))
+		return nil
 	}
 
 	sub, exists := n.subscriptions[subject]
@@ -266,21 +381,32 @@ func (n *natsProvider) Unsubscribe(topic string) error {
 	return nil
 }
 
+// Close drains the connection: the subscriptions stop receiving, the handlers finish the pending messages,
+// then the published messages are flushed, within the drain timeout
 func (n *natsProvider) Close() error {
 	n.mu.Lock()
-	defer n.mu.Unlock()
+	for _, consumeCtx := range n.consumers {
+		consumeCtx.Drain()
+	}
+	n.consumers = make(map[string]jetstream.ConsumeContext)
+	n.subscriptions = make(map[string]*nats.Subscription)
+	n.mu.Unlock()
 
-	for topic, sub := range n.subscriptions {
-		if err := sub.Unsubscribe(); err != nil {
-			n.log.GetLogger().Error("Failed to unsubscribe during close",
-				zap.String("topic", topic),
-				zap.Error(err))
+	if err := n.nc.Drain(); err != nil {
+		if stderrors.Is(err, nats.ErrConnectionClosed) {
+			return nil
 		}
+		n.nc.Close()
+		return errors.Wrap(err, "drain nats connection")
 	}
 
-	n.subscriptions = make(map[string]*nats.Subscription)
+	select {
+	case <-n.closed:
+	case <-time.After(n.cfg.DrainTimeout + time.Second):
+		n.log.GetLogger().Warn("Draining nats connection timed out")
+		n.nc.Close()
+	}
 
-	n.nc.Close()
 	return nil
 }
 
@@ -292,8 +418,8 @@ func (n *natsProvider) Request(ctx context.Context, pattern string, data any, at
 	headers := getHeaders(msg)
 	n.log.GetWrappedLogger(ctx).Debug("request to subject", zap.String("subject", msg.Subject), zap.String("type", "request"), zap.Any("headers", headers))
 
-	handler := n.Middleware(ctx, "request", func(c context.Context, msg *nats.Msg) error {
-		resp, err := n.nc.RequestMsg(msg, timeout*time.Second)
+	handler := n.Middleware(ctx, OperationRequest, func(c context.Context, msg *nats.Msg) error {
+		resp, err := n.nc.RequestMsg(msg, timeout)
 		if err != nil {
 			return err
 		}
@@ -307,7 +433,30 @@ func (n *natsProvider) Request(ctx context.Context, pattern string, data any, at
 	return handler(ctx, msg)
 }
 
-func natsResponse(resp any) *nats.Msg {
+// reject skips the message which cannot be decoded, its handler is not run.
+// A request is answered with an ErrorResponse instead of letting it time out.
+func (n *natsProvider) reject(msg *nats.Msg, err error) error {
+	n.log.GetLogger().Error("Error reading message, skipped",
+		zap.String("topic", msg.Subject),
+		zap.Error(err))
+
+	if msg.Reply != "" {
+		resp := ErrorResponse{Error: ErrorBody{Code: "bad_request", Message: err.Error()}}
+		if rerr := n.respond(msg, resp); rerr != nil {
+			n.log.GetLogger().Error("Error responding to message", zap.String("topic", msg.Subject), zap.Error(rerr))
+		}
+	}
+	return fmt.Errorf("%w: %v", errUndecodable, err)
+}
+
+func (n *natsProvider) subscribed(subject string) bool {
+	_, subscription := n.subscriptions[subject]
+	_, consumer := n.consumers[subject]
+	return subscription || consumer
+}
+
+// NewResponseMsg encodes the reply of a handler, the replies are JSON whatever the serializer of the requests
+func NewResponseMsg(resp any) *nats.Msg {
 	var data []byte
 	var err error
 	data, err = json.Marshal(resp)
@@ -325,16 +474,19 @@ const (
 	HeaderStartTime HeaderKey = "start_time"
 )
 
-func applyHeadersToContext(ctx context.Context, msg *nats.Msg) context.Context {
-	headers := msg.Header
+// ApplyHeadersToContext restores the request, user, tenant, trace and baggage of the message in the context,
+// see natsctx, and sets every header as context value keyed by HeaderKey for HeaderFromContext
+func ApplyHeadersToContext(ctx context.Context, msg *nats.Msg) context.Context {
+	ctx = natsctx.Extract(ctx, msg.Header)
 
-	for k, v := range headers {
+	for k, v := range msg.Header {
+		if len(v) == 0 {
+			continue
+		}
 		if k == string(HeaderStartTime) {
-			st, err := time.Parse(time.RFC3339Nano, v[0])
-			if err != nil {
-				return ctx
+			if st, err := time.Parse(time.RFC3339Nano, v[0]); err == nil {
+				ctx = context.WithValue(ctx, HeaderStartTime, st)
 			}
-			ctx = context.WithValue(ctx, HeaderStartTime, st)
 			continue
 		}
 		ctx = context.WithValue(ctx, HeaderKey(k), v[0])
@@ -342,3 +494,9 @@ func applyHeadersToContext(ctx context.Context, msg *nats.Msg) context.Context {
 
 	return ctx
 }
+
+// HeaderFromContext returns a header of the handled message, set by ApplyHeadersToContext
+func HeaderFromContext(ctx context.Context, key string) string {
+	value, _ := ctx.Value(HeaderKey(key)).(string)
+	return value
+}
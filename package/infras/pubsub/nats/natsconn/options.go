@@ -0,0 +1,47 @@
+// Package natsconn builds the connection options shared by the NATS clients:
+// the pubsub provider of the services, the logging core and the event providers of the gateway.
+package natsconn
+
+import (
+	"crypto/tls"
+
+	nats "github.com/nats-io/nats.go"
+
+	"github.com/gianglt2198/federation-go/package/config"
+)
+
+// Options returns the authentication and TLS options of a connection
+func Options(auth config.NATSAuthConfig, tlsConfig config.NATSTLSConfig) ([]nats.Option, error) {
+	var opts []nats.Option
+
+	switch {
+	case auth.CredentialsFile != "":
+		opts = append(opts, nats.UserCredentials(auth.CredentialsFile))
+	case auth.NKeyFile != "":
+		opt, err := nats.NkeyOptionFromSeed(auth.NKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	case auth.Token != "":
+		opts = append(opts, nats.Token(auth.Token))
+	case auth.Username != "":
+		opts = append(opts, nats.UserInfo(auth.Username, auth.Password))
+	}
+
+	if tlsConfig.Enabled {
+		// Secure must come first, RootCAs and ClientCert complete its configuration
+		opts = append(opts, nats.Secure(&tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: tlsConfig.InsecureSkipVerify, //nolint:gosec // opt-in for development clusters
+		}))
+		if tlsConfig.CAFile != "" {
+			opts = append(opts, nats.RootCAs(tlsConfig.CAFile))
+		}
+		if tlsConfig.CertFile != "" {
+			opts = append(opts, nats.ClientCert(tlsConfig.CertFile, tlsConfig.KeyFile))
+		}
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,66 @@
+// Package natsctx maps the headers of the NATS messages to the values of the context, and back.
+// The publish, request and subscribe paths share it, so that a value set before a publish is found by the handler.
+package natsctx
+
+import (
+	"context"
+
+	nats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/gianglt2198/federation-go/package/common"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/tracing"
+)
+
+// Field is a header carried by the messages and the context key of its value
+type Field struct {
+	Header string
+	Key    common.KeyType
+}
+
+// Fields are the headers mapped to context values, the W3C trace context and baggage go through the propagator
+var Fields = []Field{
+	{Header: "request_id", Key: common.KEY_REQUEST_ID},
+	{Header: "user_id", Key: common.KEY_AUTH_USER_ID},
+	{Header: "tenant_id", Key: common.KEY_TENANT_ID},
+	{Header: "trace_id", Key: common.KEY_TRACE_ID},
+	{Header: "span_id", Key: common.KEY_SPAN_ID},
+}
+
+// Inject sets the headers of the context values, the headers already set are kept
+func Inject(ctx context.Context, header nats.Header) {
+	for _, field := range Fields {
+		if header.Get(field.Header) != "" {
+			continue
+		}
+		if value, _ := ctx.Value(field.Key).(string); value != "" {
+			header.Set(field.Header, value)
+		}
+	}
+
+	carrier := propagation.MapCarrier{}
+	tracing.Propagation().Inject(ctx, carrier)
+
+	for key, val := range carrier {
+		header.Set(key, val)
+	}
+}
+
+// Extract returns the context with the values of the headers, under the common.KEY_* keys
+func Extract(ctx context.Context, header nats.Header) context.Context {
+	carrier := propagation.MapCarrier{}
+	for key, val := range header {
+		if len(val) > 0 {
+			carrier[key] = val[0]
+		}
+	}
+	ctx = tracing.Propagation().Extract(ctx, carrier)
+
+	for _, field := range Fields {
+		if value := header.Get(field.Header); value != "" {
+			ctx = context.WithValue(ctx, field.Key, value)
+		}
+	}
+
+	return ctx
+}
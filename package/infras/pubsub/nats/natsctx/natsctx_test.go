@@ -0,0 +1,198 @@
+package natsctx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gianglt2198/federation-go/package/common"
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natsctx"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natstest"
+)
+
+const waitTimeout = 5 * time.Second
+
+type client interface {
+	pubsub.Client
+	pubsub.QueueSubscriber
+	pubsub.Broker
+}
+
+// identity is what a handler reads from its context
+type identity struct {
+	RequestID string `json:"request_id"`
+	UserID    string `json:"user_id"`
+	TenantID  string `json:"tenant_id"`
+	TraceID   string `json:"trace_id"`
+	Baggage   string `json:"baggage"`
+	Deadline  bool   `json:"deadline"`
+}
+
+var (
+	traceID, _ = trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _  = trace.SpanIDFromHex("00f067aa0ba902b7")
+)
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+func newClient(t *testing.T, cfg config.NATSConfig) client {
+	t.Helper()
+
+	log, err := logging.NewLogger(config.AppConfig{Name: cfg.Name}, config.NATSConfig{})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	client, err := psnats.New(psnats.NatsParams{Log: log, Config: cfg})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+// callerContext carries the request, user, tenant, trace and baggage of a caller
+func callerContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, common.KEY_REQUEST_ID, "req-1")
+	ctx = context.WithValue(ctx, common.KEY_AUTH_USER_ID, "user-1")
+	ctx = context.WithValue(ctx, common.KEY_TENANT_ID, "tenant-1")
+
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+
+	member, err := baggage.NewMember("plan", "pro")
+	if err != nil {
+		t.Fatalf("baggage member: %v", err)
+	}
+	bag, err := baggage.New(member)
+	if err != nil {
+		t.Fatalf("baggage: %v", err)
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+func identityOf(ctx context.Context) identity {
+	value := func(key common.KeyType) string {
+		v, _ := ctx.Value(key).(string)
+		return v
+	}
+	_, deadline := ctx.Deadline()
+
+	return identity{
+		RequestID: value(common.KEY_REQUEST_ID),
+		UserID:    value(common.KEY_AUTH_USER_ID),
+		TenantID:  value(common.KEY_TENANT_ID),
+		TraceID:   trace.SpanContextFromContext(ctx).TraceID().String(),
+		Baggage:   baggage.FromContext(ctx).Member("plan").Value(),
+		Deadline:  deadline,
+	}
+}
+
+func checkIdentity(t *testing.T, got identity, deadline bool) {
+	t.Helper()
+
+	want := identity{
+		RequestID: "req-1",
+		UserID:    "user-1",
+		TenantID:  "tenant-1",
+		TraceID:   traceID.String(),
+		Baggage:   "pro",
+		Deadline:  deadline,
+	}
+	if got != want {
+		t.Fatalf("handler context = %+v, want %+v", got, want)
+	}
+}
+
+func TestPublishSubscribeRoundTrip(t *testing.T) {
+	client := newClient(t, natstest.Start(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan identity, 1)
+	client.Subscribe(ctx, "user.updated", func(ctx context.Context, _ pubsub.Message) (any, error) {
+		received <- identityOf(ctx)
+		return nil, nil
+	})
+
+	// the deadline of the publisher does not bound the consumers
+	pubCtx, pubCancel := context.WithTimeout(callerContext(t), waitTimeout)
+	defer pubCancel()
+	if err := client.Publish(pubCtx, "user.updated", []byte(`{"id":"u1"}`), nil); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		checkIdentity(t, got, false)
+	case <-time.After(waitTimeout):
+		t.Fatal("message not received")
+	}
+}
+
+func TestRequestQueueSubscribeRoundTrip(t *testing.T) {
+	client := newClient(t, natstest.Start(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := client.QueueSubscribe(ctx, "user.find", "account", func(ctx context.Context, _ pubsub.Message) (any, error) {
+		return identityOf(ctx), nil
+	})
+	if err != nil {
+		t.Fatalf("queue subscribe: %v", err)
+	}
+
+	// the deadline of the requester bounds the handler
+	reqCtx, reqCancel := context.WithTimeout(callerContext(t), waitTimeout)
+	defer reqCancel()
+
+	var got identity
+	if err := client.Request(reqCtx, "user.find", []byte(`{"id":"u1"}`), nil, waitTimeout, &got); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	checkIdentity(t, got, true)
+}
+
+func TestInjectKeepsTheSetHeaders(t *testing.T) {
+	header := nats.Header{}
+	header.Set("user_id", "user-2")
+
+	natsctx.Inject(callerContext(t), header)
+
+	if got := header.Get("user_id"); got != "user-2" {
+		t.Fatalf("user_id = %q, want the header set before", got)
+	}
+	if got := header.Get("request_id"); got != "req-1" {
+		t.Fatalf("request_id = %q, want the one of the context", got)
+	}
+	if header.Get("traceparent") == "" || header.Get("baggage") == "" {
+		t.Fatalf("trace context and baggage not injected: %v", header)
+	}
+
+	got := identityOf(natsctx.Extract(context.Background(), header))
+	if got.UserID != "user-2" || got.TraceID != traceID.String() || got.Baggage != "pro" {
+		t.Fatalf("extracted context = %+v", got)
+	}
+}
@@ -0,0 +1,155 @@
+// Package natstest runs an in process nats-server with JetStream, for the integration tests.
+// Import it from the _test.go files only, nats-server is then linked into the test binaries and never into the services.
+//
+//	func TestUserCreated(t *testing.T) {
+//		cfg := natstest.Start(t, natstest.WithJetStream("EVENTS", "user.>"))
+//		client, err := psnats.New(psnats.NatsParams{Log: log, Config: cfg})
+//		...
+//	}
+package natstest
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+
+	"github.com/gianglt2198/federation-go/package/config"
+)
+
+const readyTimeout = 10 * time.Second
+
+type (
+	// Server is an embedded nats-server listening on a random port of the loopback
+	Server struct {
+		server    *server.Server
+		storeDir  string
+		name      string
+		basePath  string
+		jetStream config.NATSJetStreamConfig
+	}
+
+	Option func(*Server, *server.Options)
+)
+
+// WithName sets the client name of the returned configuration
+func WithName(name string) Option {
+	return func(s *Server, _ *server.Options) {
+		s.name = name
+	}
+}
+
+// WithBasePath sets the base path of the returned configuration
+func WithBasePath(basePath string) Option {
+	return func(s *Server, _ *server.Options) {
+		s.basePath = basePath
+	}
+}
+
+// WithJetStream enables the JetStream consumption of the subjects in the returned configuration,
+// the stream is created by the clients on connection
+func WithJetStream(stream string, subjects ...string) Option {
+	return func(s *Server, _ *server.Options) {
+		s.jetStream = config.NATSJetStreamConfig{
+			Enabled:  true,
+			Stream:   stream,
+			Subjects: subjects,
+		}
+	}
+}
+
+// WithServerOptions changes the options of the server, e.g. to set accounts or a max payload
+func WithServerOptions(fn func(*server.Options)) Option {
+	return func(_ *Server, opts *server.Options) {
+		fn(opts)
+	}
+}
+
+// Start runs a server for the test and shuts it down on its cleanup
+func Start(tb testing.TB, opts ...Option) config.NATSConfig {
+	tb.Helper()
+
+	s, err := Run(opts...)
+	if err != nil {
+		tb.Fatalf("natstest: %v", err)
+	}
+	tb.Cleanup(s.Shutdown)
+
+	return s.Config()
+}
+
+// Run starts a server, the caller shuts it down
+func Run(opts ...Option) (*Server, error) {
+	storeDir, err := os.MkdirTemp("", "natstest-*")
+	if err != nil {
+		return nil, fmt.Errorf("create jetstream store: %w", err)
+	}
+
+	s := &Server{storeDir: storeDir, name: "natstest", basePath: "test"}
+
+	serverOpts := &server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  storeDir,
+	}
+	for _, opt := range opts {
+		opt(s, serverOpts)
+	}
+
+	s.server, err = server.NewServer(serverOpts)
+	if err != nil {
+		_ = os.RemoveAll(storeDir)
+		return nil, fmt.Errorf("new server: %w", err)
+	}
+
+	go s.server.Start()
+
+	if !s.server.ReadyForConnections(readyTimeout) {
+		s.Shutdown()
+		return nil, fmt.Errorf("server not ready after %s", readyTimeout)
+	}
+
+	return s, nil
+}
+
+// URL is the client URL of the server
+func (s *Server) URL() string {
+	return s.server.ClientURL()
+}
+
+// Config returns a configuration connecting the clients to the server,
+// JetStream consumption is enabled by WithJetStream
+func (s *Server) Config() config.NATSConfig {
+	return config.NATSConfig{
+		Enabled:         true,
+		Driver:          config.NATSDriverNATS,
+		Name:            s.name,
+		Endpoint:        s.URL(),
+		BasePath:        s.basePath,
+		AllowReconnect:  false,
+		PingInterval:    time.Minute,
+		ConnectAttempts: 1,
+		ConnectBackoff:  100 * time.Millisecond,
+		DrainTimeout:    5 * time.Second,
+		JetStream:       s.jetStream,
+	}
+}
+
+// Server exposes the embedded server, e.g. to check its JetStream state
+func (s *Server) Server() *server.Server {
+	return s.server
+}
+
+// Shutdown stops the server and removes its JetStream store
+func (s *Server) Shutdown() {
+	if s.server != nil {
+		s.server.Shutdown()
+		s.server.WaitForShutdown()
+	}
+	_ = os.RemoveAll(s.storeDir)
+}
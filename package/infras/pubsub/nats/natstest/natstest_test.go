@@ -0,0 +1,163 @@
+package natstest_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natstest"
+)
+
+const waitTimeout = 5 * time.Second
+
+type client interface {
+	pubsub.Client
+	pubsub.QueueSubscriber
+}
+
+func newClient(t *testing.T, cfg config.NATSConfig) client {
+	t.Helper()
+
+	log, err := logging.NewLogger(config.AppConfig{Name: cfg.Name}, config.NATSConfig{})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	client, err := psnats.New(psnats.NatsParams{Log: log, Config: cfg})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func TestStartConfig(t *testing.T) {
+	cfg := natstest.Start(t, natstest.WithName("account"), natstest.WithBasePath("dev"))
+
+	if !cfg.Enabled || cfg.Driver != config.NATSDriverNATS {
+		t.Fatalf("config not enabled for the nats driver: %+v", cfg)
+	}
+	if cfg.Name != "account" || cfg.BasePath != "dev" {
+		t.Fatalf("name and base path not applied: %q %q", cfg.Name, cfg.BasePath)
+	}
+	if cfg.JetStream.Enabled {
+		t.Fatal("jetstream consumption enabled without WithJetStream")
+	}
+
+	nc, err := nats.Connect(cfg.URLs())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer nc.Close()
+}
+
+func TestRunShutdown(t *testing.T) {
+	s, err := natstest.Run()
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if !s.Server().JetStreamEnabled() {
+		t.Fatal("jetstream not enabled on the server")
+	}
+
+	s.Shutdown()
+
+	if _, err := nats.Connect(s.URL(), nats.NoReconnect()); err == nil {
+		t.Fatal("connected after shutdown")
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	client := newClient(t, natstest.Start(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan pubsub.Message, 1)
+	client.Subscribe(ctx, "user.created", func(_ context.Context, msg pubsub.Message) (any, error) {
+		received <- msg
+		return nil, nil
+	})
+
+	if err := client.Publish(ctx, "user.created", []byte(`{"id":"u1"}`), nil); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if msg.Topic != "test.user.created" {
+			t.Fatalf("topic = %q, want the base path prefixed subject", msg.Topic)
+		}
+		if string(msg.Data) != `{"id":"u1"}` {
+			t.Fatalf("data = %s", msg.Data)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("message not received")
+	}
+}
+
+func TestJetStreamRedeliveryAndDeadLetter(t *testing.T) {
+	cfg := natstest.Start(t, natstest.WithJetStream("EVENTS", "user.>"))
+	cfg.JetStream.MaxDeliver = 2
+	client := newClient(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var deliveries atomic.Int32
+	err := client.QueueSubscribe(ctx, "user.created", "account", func(context.Context, pubsub.Message) (any, error) {
+		deliveries.Add(1)
+		return nil, errors.New("boom")
+	})
+	if err != nil {
+		t.Fatalf("queue subscribe: %v", err)
+	}
+
+	if err := client.Publish(ctx, "user.created", []byte(`{"id":"u1"}`), nil); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	nc, err := nats.Connect(cfg.URLs())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer nc.Close()
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatalf("jetstream: %v", err)
+	}
+
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		stream, err := js.Stream(ctx, cfg.JetStream.DeadLetterStream())
+		if err != nil {
+			t.Fatalf("dead-letter stream: %v", err)
+		}
+		info, err := stream.Info(ctx)
+		if err != nil {
+			t.Fatalf("dead-letter stream info: %v", err)
+		}
+		if info.State.Msgs == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dead letters = %d after %d deliveries, want 1", info.State.Msgs, deliveries.Load())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if got := deliveries.Load(); got != 2 {
+		t.Fatalf("deliveries = %d, want MaxDeliver", got)
+	}
+}
@@ -0,0 +1,314 @@
+package psnats
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+)
+
+// Headers of the chunked replies: the requester asks for them with HeaderChunked,
+// the first chunk carries the status and the headers of the response, the last one HeaderChunkEOF.
+// The requests exceeding the max payload are chunked the same way, HeaderChunkInbox is set on the
+// reply to their first chunk.
+const (
+	HeaderChunked        = "chunked"
+	HeaderChunkSeq       = "chunk_seq"
+	HeaderChunkEOF       = "chunk_eof"
+	HeaderChunkInbox     = "chunk_inbox"
+	HeaderResponseStatus = "resp_status"
+	// HeaderResponsePrefix prefixes the headers of the response, to keep them apart from the message ones
+	HeaderResponsePrefix = "resp-"
+)
+
+// status of the messages the server sends to the requests without subscriber, unexported by the client
+const (
+	natsStatusHeader = "Status"
+	natsNoResponders = "503"
+)
+
+// chunkHeadroom leaves room in the max payload for the headers of the chunks
+const chunkHeadroom = 16 * 1024
+
+// requestChunkTimeout bounds the wait of each chunk of a chunked request by the responder
+const requestChunkTimeout = 5 * time.Second
+
+func (n *natsProvider) chunkSize() int {
+	return int(n.nc.MaxPayload()) - chunkHeadroom
+}
+
+// respond replies to a request: raw responses as chunks when the requester accepts them, as their body otherwise
+func (n *natsProvider) respond(msg *nats.Msg, resp any) error {
+	raw, ok := resp.(*pubsub.Response)
+	if !ok {
+		if msg.Header.Get(HeaderChunked) == "" {
+			return msg.RespondMsg(NewResponseMsg(resp))
+		}
+		// the replies of the middlewares, e.g. the ErrorResponse of a panic, are chunked too
+		raw = NewRawResponse(resp)
+	}
+
+	if msg.Header.Get(HeaderChunked) == "" {
+		return msg.Respond(raw.Body)
+	}
+
+	size := n.chunkSize()
+	for seq, offset := 0, 0; ; seq++ {
+		end := min(offset+size, len(raw.Body))
+
+		chunk := ResponseChunk(msg.Reply, seq, raw, raw.Body[offset:end], end == len(raw.Body))
+		if err := n.nc.PublishMsg(chunk); err != nil {
+			return errors.Wrapf(err, "publish chunk %d", seq)
+		}
+
+		if end == len(raw.Body) {
+			return nil
+		}
+		offset = end
+	}
+}
+
+// NewRawResponse frames the reply of a handler, or of a middleware, as a raw JSON response.
+// An ErrorResponse is an internal server error, so that the requester surfaces it as such.
+func NewRawResponse(resp any) *pubsub.Response {
+	status := http.StatusOK
+	switch resp.(type) {
+	case ErrorResponse, *ErrorResponse:
+		status = http.StatusInternalServerError
+	}
+
+	return &pubsub.Response{
+		Status: status,
+		Header: map[string]string{"Content-Type": "application/json"},
+		Body:   NewResponseMsg(resp).Data,
+	}
+}
+
+// ResponseChunk builds a chunk of the reply to a chunked request
+func ResponseChunk(reply string, seq int, resp *pubsub.Response, data []byte, eof bool) *nats.Msg {
+	chunk := nats.NewMsg(reply)
+	chunk.Header.Set(HeaderChunkSeq, strconv.Itoa(seq))
+	if seq == 0 {
+		status := resp.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		chunk.Header.Set(HeaderResponseStatus, strconv.Itoa(status))
+		for k, v := range resp.Header {
+			chunk.Header.Set(HeaderResponsePrefix+k, v)
+		}
+	}
+	if eof {
+		chunk.Header.Set(HeaderChunkEOF, "true")
+	}
+	chunk.Data = data
+	return chunk
+}
+
+// RequestRaw sends the data as it is and gathers the chunks of the reply, the data exceeding
+// the max payload is sent in chunks too. The timeout bounds the wait of each chunk, so that large
+// requests and replies are not cut by it.
+func (n *natsProvider) RequestRaw(ctx context.Context, pattern string, data []byte, attrs map[string]string, timeout time.Duration) (*pubsub.Response, error) {
+	msg, err := n.factory.NewMessage(ctx, pattern, data, attrs)
+	if err != nil {
+		return nil, errors.Wrap(err, "new message error")
+	}
+	msg.Header.Set(HeaderChunked, "true")
+
+	n.log.GetWrappedLogger(ctx).Debug("request to subject", zap.String("subject", msg.Subject), zap.String("type", "request_raw"))
+
+	var resp *pubsub.Response
+	handler := n.Middleware(ctx, OperationRequest, func(c context.Context, msg *nats.Msg) error {
+		inbox := n.nc.NewRespInbox()
+		sub, err := n.nc.SubscribeSync(inbox)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = sub.Unsubscribe() }()
+
+		next := func() (*nats.Msg, error) {
+			chunkCtx, cancel := context.WithTimeout(c, timeout)
+			defer cancel()
+
+			chunk, err := sub.NextMsgWithContext(chunkCtx)
+			if err != nil && errors.Cause(err) == context.DeadlineExceeded {
+				return nil, nats.ErrTimeout
+			}
+			return chunk, err
+		}
+
+		msg.Reply = inbox
+		if err := n.publishRequest(msg, next); err != nil {
+			return err
+		}
+
+		gatherer := NewChunkGatherer()
+		for !gatherer.Done() {
+			chunk, err := next()
+			if err != nil {
+				return err
+			}
+			if err := gatherer.Add(chunk); err != nil {
+				return err
+			}
+		}
+
+		resp = gatherer.Response()
+		return nil
+	}, n.middlewares...)
+
+	if err := handler(ctx, msg); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// publishRequest publishes the request, in chunks when it exceeds the max payload. The first chunk goes
+// to the subject, the responder answers it with the inbox of the next ones, so that they all reach the
+// member of the queue group which received the first one.
+func (n *natsProvider) publishRequest(msg *nats.Msg, next func() (*nats.Msg, error)) error {
+	size := n.chunkSize()
+	if len(msg.Data) <= size {
+		return n.nc.PublishMsg(msg)
+	}
+
+	data := msg.Data
+	first := &nats.Msg{Subject: msg.Subject, Reply: msg.Reply, Header: msg.Header, Data: data[:size]}
+	first.Header.Set(HeaderChunkSeq, "0")
+	if err := n.nc.PublishMsg(first); err != nil {
+		return errors.Wrap(err, "publish request chunk 0")
+	}
+
+	ack, err := next()
+	if err != nil {
+		return err
+	}
+	if len(ack.Data) == 0 && ack.Header.Get(natsStatusHeader) == natsNoResponders {
+		return nats.ErrNoResponders
+	}
+	inbox := ack.Header.Get(HeaderChunkInbox)
+	if inbox == "" {
+		return fmt.Errorf("request to %s exceeds the max payload and the responder does not accept chunks", msg.Subject)
+	}
+
+	for seq, offset := 1, size; offset < len(data); seq++ {
+		end := min(offset+size, len(data))
+
+		chunk := nats.NewMsg(inbox)
+		chunk.Header.Set(HeaderChunkSeq, strconv.Itoa(seq))
+		if end == len(data) {
+			chunk.Header.Set(HeaderChunkEOF, "true")
+		}
+		chunk.Data = data[offset:end]
+		if err := n.nc.PublishMsg(chunk); err != nil {
+			return errors.Wrapf(err, "publish request chunk %d", seq)
+		}
+		offset = end
+	}
+	return nil
+}
+
+// gatherRequest reads the next chunks of a chunked request, into the data of its first one.
+// The requests sent whole are returned as they are.
+func (n *natsProvider) gatherRequest(msg *nats.Msg) (*nats.Msg, error) {
+	if msg.Reply == "" || msg.Header.Get(HeaderChunkSeq) != "0" || msg.Header.Get(HeaderChunkEOF) == "true" {
+		return msg, nil
+	}
+
+	inbox := n.nc.NewInbox()
+	sub, err := n.nc.SubscribeSync(inbox)
+	if err != nil {
+		return nil, errors.Wrap(err, "subscribe to the request chunks")
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	ack := nats.NewMsg(msg.Reply)
+	ack.Header.Set(HeaderChunkInbox, inbox)
+	if err := n.nc.PublishMsg(ack); err != nil {
+		return nil, errors.Wrap(err, "accept the request chunks")
+	}
+
+	data := append([]byte{}, msg.Data...)
+	for seq := 1; ; seq++ {
+		chunk, err := sub.NextMsg(requestChunkTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("request chunk %d: %w", seq, err)
+		}
+		if got := chunk.Header.Get(HeaderChunkSeq); got != strconv.Itoa(seq) {
+			return nil, fmt.Errorf("request chunk %s received, %d expected", got, seq)
+		}
+
+		data = append(data, chunk.Data...)
+		if chunk.Header.Get(HeaderChunkEOF) == "true" {
+			break
+		}
+	}
+
+	msg.Header.Del(HeaderChunkSeq)
+	msg.Data = data
+	return msg, nil
+}
+
+// ChunkGatherer rebuilds a raw response from its chunks, which must arrive in order
+type ChunkGatherer struct {
+	resp *pubsub.Response
+	next int
+	done bool
+	body []byte
+}
+
+func NewChunkGatherer() *ChunkGatherer {
+	return &ChunkGatherer{}
+}
+
+func (g *ChunkGatherer) Add(chunk *nats.Msg) error {
+	if len(chunk.Data) == 0 && chunk.Header.Get(natsStatusHeader) == natsNoResponders {
+		return nats.ErrNoResponders
+	}
+
+	seq, err := strconv.Atoi(chunk.Header.Get(HeaderChunkSeq))
+	if err != nil {
+		return fmt.Errorf("reply is not chunked: %w", err)
+	}
+	if seq != g.next {
+		return fmt.Errorf("reply chunk %d received, %d expected", seq, g.next)
+	}
+	g.next++
+
+	if seq == 0 {
+		status, err := strconv.Atoi(chunk.Header.Get(HeaderResponseStatus))
+		if err != nil {
+			status = http.StatusOK
+		}
+		g.resp = &pubsub.Response{Status: status, Header: make(map[string]string)}
+		for k := range chunk.Header {
+			if name, ok := strings.CutPrefix(k, HeaderResponsePrefix); ok {
+				g.resp.Header[name] = chunk.Header.Get(k)
+			}
+		}
+	}
+
+	g.body = append(g.body, chunk.Data...)
+	g.done = chunk.Header.Get(HeaderChunkEOF) == "true"
+	return nil
+}
+
+func (g *ChunkGatherer) Done() bool {
+	return g.done
+}
+
+func (g *ChunkGatherer) Response() *pubsub.Response {
+	if g.resp == nil {
+		return nil
+	}
+	g.resp.Body = g.body
+	return g.resp
+}
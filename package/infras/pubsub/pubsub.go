@@ -28,11 +28,24 @@ type (
 		Close() error
 	}
 
+	// RawBroker requests raw replies, streamed in chunks so that they can exceed the max payload of the server
+	RawBroker interface {
+		RequestRaw(ctx context.Context, pattern string, data []byte, attrs map[string]string, timeout time.Duration) (*Response, error)
+	}
+
 	Message struct {
 		Topic string
 		Data  []byte
 	}
 
+	// Response is a raw reply of a handler: RequestRaw receives it whole,
+	// Request receives its body, which must then be JSON
+	Response struct {
+		Status int
+		Header map[string]string
+		Body   []byte
+	}
+
 	Handler func(ctx context.Context, msg Message) (any, error)
 
 	Client interface {
@@ -0,0 +1,335 @@
+package serdes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/proto"
+)
+
+// Content types and encodings declared by the messages, the HTTP way
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeMsgPack  = "application/msgpack"
+	ContentTypeProtobuf = "application/protobuf"
+
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+)
+
+// MaxDecompressedSize bounds the payloads of the compressors, the compressed data being chosen by the sender
+const MaxDecompressedSize = 64 << 20
+
+var ErrDecompressedTooLarge = errors.New("serdes: decompressed payload too large")
+
+// aliases are the short names of the configurations, and the legacy content types
+var aliases = map[string]string{
+	"json":                   ContentTypeJSON,
+	"msgpack":                ContentTypeMsgPack,
+	"application/x-msgpack":  ContentTypeMsgPack,
+	"protobuf":               ContentTypeProtobuf,
+	"proto":                  ContentTypeProtobuf,
+	"application/x-protobuf": ContentTypeProtobuf,
+}
+
+type (
+	// Codec encodes the payload of a message into its content type and decodes it back to the payload bytes
+	Codec interface {
+		ContentType() string
+		Encode(in any) ([]byte, error)
+		Decode(data []byte) ([]byte, error)
+	}
+
+	// Compressor applies a content encoding to the encoded payload
+	Compressor interface {
+		Encoding() string
+		Compress(data []byte) ([]byte, error)
+		Decompress(data []byte) ([]byte, error)
+	}
+
+	// Registry finds the codec and the compressor declared by a message.
+	// The messages without content type are decoded with the default one, msgpack unless set otherwise.
+	Registry struct {
+		defaultContentType string
+		codecs             map[string]Codec
+		compressors        map[string]Compressor
+	}
+
+	RegistryOption func(*Registry)
+)
+
+// WithDefaultContentType sets the content type of the messages without one
+func WithDefaultContentType(contentType string) RegistryOption {
+	return func(r *Registry) {
+		r.defaultContentType = ContentType(contentType)
+	}
+}
+
+// NewRegistry returns a registry of the JSON, msgpack and protobuf codecs, and of the gzip and zstd compressors
+func NewRegistry(opts ...RegistryOption) *Registry {
+	r := &Registry{
+		defaultContentType: ContentTypeMsgPack,
+		codecs:             make(map[string]Codec),
+		compressors:        make(map[string]Compressor),
+	}
+
+	r.Register(jsonCodec{})
+	r.Register(NewSerializerCodec(ContentTypeMsgPack, NewMsgPack()))
+	r.Register(protobufCodec{})
+	r.RegisterCompressor(gzipCompressor{})
+	r.RegisterCompressor(&zstdCompressor{})
+
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Registry) Register(codec Codec) {
+	r.codecs[codec.ContentType()] = codec
+}
+
+func (r *Registry) RegisterCompressor(compressor Compressor) {
+	r.compressors[compressor.Encoding()] = compressor
+}
+
+// DefaultContentType is the content type of the messages without one
+func (r *Registry) DefaultContentType() string {
+	return r.defaultContentType
+}
+
+// Encode encodes the payload into the content type, then compresses it with the encoding when there is one
+func (r *Registry) Encode(contentType, encoding string, in any) ([]byte, error) {
+	codec, err := r.codec(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := codec.Encode(in)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", codec.ContentType(), err)
+	}
+
+	compressor, err := r.compressor(encoding)
+	if err != nil || compressor == nil {
+		return data, err
+	}
+	return compressor.Compress(data)
+}
+
+// Decode reverses Encode, the empty content type is the default one
+func (r *Registry) Decode(contentType, encoding string, data []byte) ([]byte, error) {
+	compressor, err := r.compressor(encoding)
+	if err != nil {
+		return nil, err
+	}
+	if compressor != nil {
+		if data, err = compressor.Decompress(data); err != nil {
+			return nil, fmt.Errorf("decompress %s: %w", compressor.Encoding(), err)
+		}
+	}
+
+	codec, err := r.codec(contentType)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decode(data)
+}
+
+// Supports returns an error when the content type has no codec, or the encoding no compressor
+func (r *Registry) Supports(contentType, encoding string) error {
+	if _, err := r.codec(contentType); err != nil {
+		return err
+	}
+	_, err := r.compressor(encoding)
+	return err
+}
+
+func (r *Registry) codec(contentType string) (Codec, error) {
+	if contentType == "" {
+		contentType = r.defaultContentType
+	}
+	codec, ok := r.codecs[ContentType(contentType)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	return codec, nil
+}
+
+func (r *Registry) compressor(encoding string) (Compressor, error) {
+	if encoding == "" || encoding == EncodingIdentity {
+		return nil, nil
+	}
+	compressor, ok := r.compressors[strings.ToLower(encoding)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	return compressor, nil
+}
+
+// ContentType resolves the short names, e.g. "json", and drops the parameters, e.g. "; charset=utf-8"
+func ContentType(contentType string) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if alias, ok := aliases[contentType]; ok {
+		return alias
+	}
+	return contentType
+}
+
+// serializerCodec frames the payload with a Serializer, the way the services always did with msgpack
+type serializerCodec struct {
+	contentType string
+	serializer  Serializer
+}
+
+func NewSerializerCodec(contentType string, serializer Serializer) Codec {
+	return &serializerCodec{contentType: contentType, serializer: serializer}
+}
+
+func (c *serializerCodec) ContentType() string {
+	return c.contentType
+}
+
+func (c *serializerCodec) Encode(in any) ([]byte, error) {
+	return c.serializer.Encode(in)
+}
+
+func (c *serializerCodec) Decode(data []byte) ([]byte, error) {
+	var payload []byte
+	if err := c.serializer.Decode(data, &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// jsonCodec sends the bytes as they are, expecting them to be JSON already, and marshals the other values
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Encode(in any) ([]byte, error) {
+	switch v := in.(type) {
+	case []byte:
+		return v, nil
+	case json.RawMessage:
+		return v, nil
+	default:
+		return json.Marshal(in)
+	}
+}
+
+func (jsonCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// protobufCodec marshals the proto messages, the bytes are expected to be marshalled already
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobufCodec) Encode(in any) ([]byte, error) {
+	switch v := in.(type) {
+	case []byte:
+		return v, nil
+	case proto.Message:
+		return proto.Marshal(v)
+	default:
+		return nil, fmt.Errorf("%T is not a proto message", in)
+	}
+}
+
+func (protobufCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string {
+	return EncodingGzip
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > MaxDecompressedSize {
+		return nil, ErrDecompressedTooLarge
+	}
+	return decompressed, nil
+}
+
+// zstdCompressor shares a stateless encoder and decoder, both are safe for concurrent EncodeAll and DecodeAll.
+// The decoder is bounded by MaxDecompressedSize like the gzip reader.
+type zstdCompressor struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (c *zstdCompressor) Encoding() string {
+	return EncodingZstd
+}
+
+func (c *zstdCompressor) init() error {
+	c.once.Do(func() {
+		if c.encoder, c.err = zstd.NewWriter(nil); c.err != nil {
+			return
+		}
+		c.decoder, c.err = zstd.NewReader(nil,
+			zstd.WithDecoderMaxMemory(MaxDecompressedSize),
+			zstd.WithDecoderMaxWindow(MaxDecompressedSize),
+		)
+	})
+	return c.err
+}
+
+func (c *zstdCompressor) Compress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	decompressed, err := c.decoder.DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, ErrDecompressedTooLarge
+	}
+	return decompressed, err
+}
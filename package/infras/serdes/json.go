@@ -0,0 +1,19 @@
+package serdes
+
+import "encoding/json"
+
+type jsonSerializer struct{}
+
+var _ Serializer = (*jsonSerializer)(nil)
+
+func NewJSON() Serializer {
+	return &jsonSerializer{}
+}
+
+func (j *jsonSerializer) Encode(data any) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (j *jsonSerializer) Decode(data []byte, result any) error {
+	return json.Unmarshal(data, result)
+}
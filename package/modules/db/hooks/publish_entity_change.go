@@ -98,46 +98,64 @@ func extractEntityIDs(ctx context.Context, m ent.Mutation, entity interface{}) [
 	return []string{}
 }
 
+// MutateWithEntityEvent runs the mutation and returns the event of the changed entities,
+// nil when the change tracking is skipped or no entity changed
+func MutateWithEntityEvent(ctx context.Context, m ent.Mutation, next ent.Mutator) (ent.Value, *EntityEvent, error) {
+	if ctx.Value(DataChangeKey{}) == true {
+		value, err := next.Mutate(ctx, m)
+		return value, nil, err
+	}
+	data, oldData := extractChangedFields(ctx, m)
+	value, err := next.Mutate(ctx, m)
+	if err != nil {
+		return value, nil, err
+	}
+
+	IDs := extractEntityIDs(ctx, m, value)
+	if len(IDs) == 0 {
+		return value, nil, nil
+	}
+
+	data["ids"] = IDs
+	return value, &EntityEvent{
+		Action:  determineAction(m),
+		Data:    data,
+		OldData: oldData,
+	}, nil
+}
+
+// EntityEventSubject is the subject of the entity change events, <service>.<entity>.changed
+func EntityEventSubject(serviceName, entityType string) string {
+	return fmt.Sprintf("%s.%s.changed", strings.ToLower(serviceName), strings.ToLower(entityType))
+}
+
 func PublishEntityChangeHook(serviceName string, publisher pubsub.Publisher, log *logging.Logger) ent.Hook {
 	return func(next ent.Mutator) ent.Mutator {
 		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
-			if ctx.Value(DataChangeKey{}) == true {
-				return next.Mutate(ctx, m)
-			}
-			data, oldData := extractChangedFields(ctx, m)
-			value, err := next.Mutate(ctx, m)
-			if err != nil {
+			value, event, err := MutateWithEntityEvent(ctx, m, next)
+			if err != nil || event == nil {
 				return value, err
 			}
 
-			IDs := extractEntityIDs(ctx, m, value)
-			if len(IDs) > 0 {
-				data["ids"] = IDs
-				event := EntityEvent{
-					Action:  determineAction(m),
-					Data:    data,
-					OldData: oldData,
+			publish := func(ctx context.Context) {
+				eventData, err := json.Marshal(event)
+				if err != nil {
+					log.GetWrappedLogger(ctx).Error("Failed to marshal event data", zap.Error(err))
+					return
 				}
 
-				publish := func(ctx context.Context) {
-					eventData, err := json.Marshal(event)
-					if err != nil {
-						log.GetWrappedLogger(ctx).Error("Failed to marshal event data", zap.Error(err))
-						return
-					}
-
-					subject := fmt.Sprintf("%s.%s.changed", strings.ToLower(serviceName), strings.ToLower(m.Type()))
-					if err = publisher.Publish(ctx, subject, eventData, nil); err != nil {
-						log.GetWrappedLogger(ctx).Error("Failed to send NATS message", zap.Error(err))
-					} else {
-						log.GetWrappedLogger(ctx).Debug("Event published to NATS subject", zap.String("subject", subject), zap.String("data", string(eventData)))
-					}
+				subject := EntityEventSubject(serviceName, m.Type())
+				if err = publisher.Publish(ctx, subject, eventData, nil); err != nil {
+					log.GetWrappedLogger(ctx).Error("Failed to send NATS message", zap.Error(err))
+				} else {
+					log.GetWrappedLogger(ctx).Debug("Event published to NATS subject", zap.String("subject", subject), zap.String("data", string(eventData)))
 				}
-
-				requestID := utils.GetRequestIDFromCtx(ctx)
-				newCtx := context.WithValue(context.Background(), common.KEY_REQUEST_ID, requestID)
-				publish(newCtx)
 			}
+
+			requestID := utils.GetRequestIDFromCtx(ctx)
+			newCtx := context.WithValue(context.Background(), common.KEY_REQUEST_ID, requestID)
+			publish(newCtx)
+
 			return value, nil
 		})
 	}
@@ -0,0 +1,183 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"entgo.io/ent"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/common"
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+// FederationEvent is the payload expected by the EDFS subscriptions of the gateway:
+// the key of the entity, whose fields are then resolved by the subgraphs
+type FederationEvent struct {
+	TypeName string `json:"__typename"`
+	ID       string `json:"id"`
+}
+
+// FederationMessage is a FederationEvent with the subject it is published on
+type FederationMessage struct {
+	Subject string
+	Event   FederationEvent
+}
+
+// FederationEvents maps the ent types to the federation events of their mutations
+type FederationEvents map[string]config.EntityEventConfig
+
+func NewFederationEvents(entities []config.EntityEventConfig) FederationEvents {
+	events := make(FederationEvents, len(entities))
+	for _, entity := range entities {
+		events[entity.Entity] = entity
+	}
+	return events
+}
+
+// Mutate runs the mutation and returns a message per mutated entity, on the subject configured for its
+// ent type and the action. The messages whose subject cannot be built are logged and left out.
+func (f FederationEvents) Mutate(ctx context.Context, m ent.Mutation, next ent.Mutator, log *logging.Logger) (ent.Value, []FederationMessage, error) {
+	cfg, ok := f[m.Type()]
+	if !ok || ctx.Value(DataChangeKey{}) == true {
+		value, err := next.Mutate(ctx, m)
+		return value, nil, err
+	}
+
+	action := determineAction(m)
+	subject, ok := cfg.Subjects[string(action)]
+	if !ok {
+		value, err := next.Mutate(ctx, m)
+		return value, nil, err
+	}
+
+	data := mutatedFields(m)
+
+	// deleted entities cannot be queried afterwards, their ids are read before the mutation
+	var IDs []string
+	if action == Delete {
+		IDs = extractEntityIDs(ctx, m, nil)
+	}
+
+	value, err := next.Mutate(ctx, m)
+	if err != nil {
+		return value, nil, err
+	}
+
+	if action != Delete {
+		IDs = extractEntityIDs(ctx, m, value)
+	}
+
+	messages := make([]FederationMessage, 0, len(IDs))
+	for _, id := range IDs {
+		eventSubject, err := federationSubject(subject, id, data)
+		if err != nil {
+			log.GetWrappedLogger(ctx).Error("Failed to build federation event subject",
+				zap.String("subject", subject), zap.String("id", id), zap.Error(err))
+			continue
+		}
+		messages = append(messages, FederationMessage{
+			Subject: eventSubject,
+			Event: FederationEvent{
+				TypeName: cfg.TypeName,
+				ID:       id,
+			},
+		})
+	}
+
+	return value, messages, nil
+}
+
+// PublishFederationEventHook publishes a FederationEvent per mutated entity on the subjects configured for
+// its ent type and the action, e.g. "userUpdated.{id}". It is the companion of PublishEntityChangeHook,
+// whose events are meant for the services rather than for the gateway. outbox.FederationHook replaces it
+// when the outbox is enabled.
+func PublishFederationEventHook(publisher pubsub.Publisher, log *logging.Logger, entities []config.EntityEventConfig) ent.Hook {
+	events := NewFederationEvents(entities)
+
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			value, messages, err := events.Mutate(ctx, m, next, log)
+			if err != nil || len(messages) == 0 {
+				return value, err
+			}
+
+			requestID := utils.GetRequestIDFromCtx(ctx)
+			newCtx := context.WithValue(context.Background(), common.KEY_REQUEST_ID, requestID)
+
+			for _, message := range messages {
+				publishFederationEvent(newCtx, publisher, log, message.Subject, message.Event)
+			}
+
+			return value, nil
+		})
+	}
+}
+
+func publishFederationEvent(ctx context.Context, publisher pubsub.Publisher, log *logging.Logger, subject string, event FederationEvent) {
+	eventData, err := json.Marshal(event)
+	if err != nil {
+		log.GetWrappedLogger(ctx).Error("Failed to marshal federation event", zap.Error(err))
+		return
+	}
+
+	if err = publisher.Publish(ctx, subject, eventData, nil); err != nil {
+		log.GetWrappedLogger(ctx).Error("Failed to send NATS message", zap.Error(err))
+	} else {
+		log.GetWrappedLogger(ctx).Debug("Federation event published to NATS subject", zap.String("subject", subject), zap.String("data", string(eventData)))
+	}
+}
+
+// mutatedFields returns the new values of the mutation, without querying the old ones
+func mutatedFields(m ent.Mutation) map[string]interface{} {
+	data := make(map[string]interface{})
+	for _, field := range m.Fields() {
+		if value, ok := m.Field(field); ok {
+			data[field] = value
+		}
+	}
+	return data
+}
+
+// federationSubject fills the subject template with the id and the mutated fields. The values are single tokens
+// of the subject, a value with a separator, a wildcard or a whitespace would publish to other subscribers.
+func federationSubject(template, id string, data map[string]interface{}) (string, error) {
+	if err := validateSubjectToken(id); err != nil {
+		return "", fmt.Errorf("id: %w", err)
+	}
+	replacements := []string{"{id}", id}
+
+	for field, value := range data {
+		placeholder := "{" + field + "}"
+		if !strings.Contains(template, placeholder) {
+			continue
+		}
+
+		token := fmt.Sprint(value)
+		if err := validateSubjectToken(token); err != nil {
+			return "", fmt.Errorf("field %s: %w", field, err)
+		}
+		replacements = append(replacements, placeholder, token)
+	}
+	return strings.NewReplacer(replacements...).Replace(template), nil
+}
+
+// validateSubjectToken rejects the values which are not a single literal token of a NATS subject
+func validateSubjectToken(token string) error {
+	if token == "" {
+		return errors.New("empty subject token")
+	}
+	for _, r := range token {
+		if r == '.' || r == '*' || r == '>' || unicode.IsSpace(r) {
+			return fmt.Errorf("invalid character %q in subject token %q", r, token)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package invalidation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/infras/cache"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	"github.com/gianglt2198/federation-go/package/modules/db/hooks"
+)
+
+// IDPlaceholder is replaced by the ID of the changed entity in the keys and the tags of an Entity
+const IDPlaceholder = "{id}"
+
+// Entity maps the changes of an entity type to the cache keys to evict
+type Entity struct {
+	// Service and Name are those of the subject <service>.<entity>.changed, see hooks.EntityEventSubject
+	Service string
+	Name    string
+	// Keys are the keys caching an entity, e.g. "catalog:product:{id}".
+	// The glob patterns, e.g. "catalog:products:*", are resolved with the Scan of the cache.
+	Keys []string
+	// Tags are invalidated in addition to the cache.EntityTag of the entity, e.g. "category:products"
+	Tags []string
+}
+
+// Subject is the subject of the change events of the entity
+func (e Entity) Subject() string {
+	return hooks.EntityEventSubject(e.Service, e.Name)
+}
+
+// Invalidator evicts the keys of the changed entities: the keys of their Entity, and the keys tagged
+// with their cache.EntityTag, e.g. every key tagged "product:prod123" once the product prod123 changes
+type Invalidator struct {
+	cache cache.Cache
+	log   *logging.Logger
+
+	entities map[string]Entity
+	mu       sync.RWMutex
+}
+
+func NewInvalidator(c cache.Cache, log *logging.Logger, entities ...Entity) *Invalidator {
+	i := &Invalidator{
+		cache:    c,
+		log:      log,
+		entities: make(map[string]Entity),
+	}
+	i.Register(entities...)
+	return i
+}
+
+// Register maps the entities, replacing the previous mapping of their subject.
+// The entities registered after Subscribe are only invalidated by the calls to Invalidate.
+func (i *Invalidator) Register(entities ...Entity) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, entity := range entities {
+		i.entities[entity.Subject()] = entity
+	}
+}
+
+// Entities returns the registered entities
+func (i *Invalidator) Entities() []Entity {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	entities := make([]Entity, 0, len(i.entities))
+	for _, entity := range i.entities {
+		entities = append(entities, entity)
+	}
+	return entities
+}
+
+// Invalidate evicts the keys of the entities of the ids, the unregistered entities only have their tags
+func (i *Invalidator) Invalidate(ctx context.Context, service, name string, ids ...string) error {
+	i.mu.RLock()
+	entity, ok := i.entities[hooks.EntityEventSubject(service, name)]
+	i.mu.RUnlock()
+
+	if !ok {
+		entity = Entity{Service: service, Name: name}
+	}
+	return i.invalidate(ctx, entity, ids)
+}
+
+func (i *Invalidator) invalidate(ctx context.Context, entity Entity, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var keys, patterns, tags []string
+	for _, id := range ids {
+		tags = append(tags, cache.EntityTag(entity.Name, id))
+		for _, tag := range entity.Tags {
+			tags = append(tags, expand(tag, id))
+		}
+
+		for _, key := range entity.Keys {
+			key = expand(key, id)
+			if strings.ContainsAny(key, "*?[") {
+				patterns = append(patterns, key)
+			} else {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	var errs []error
+	for _, pattern := range patterns {
+		found, err := cache.ScanKeys(ctx, i.cache, pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scan %s: %w", pattern, err))
+			continue
+		}
+		keys = append(keys, found...)
+	}
+
+	if len(keys) > 0 {
+		if err := i.cache.MDelete(ctx, keys); err != nil {
+			errs = append(errs, fmt.Errorf("delete keys: %w", err))
+		}
+	}
+
+	if _, err := cache.InvalidateTags(ctx, i.cache, tags...); err != nil && !errors.Is(err, cache.ErrTagsNotSupported) {
+		errs = append(errs, fmt.Errorf("invalidate tags: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Subscribe subscribes to the change events of the registered entities, with the subscribe of the caller:
+// a queue subscription for the caches shared by the replicas, a subscription for the in process ones
+func (i *Invalidator) Subscribe(ctx context.Context, subscribe func(ctx context.Context, subject string, handler pubsub.Handler) error) error {
+	for _, entity := range i.Entities() {
+		if err := subscribe(ctx, entity.Subject(), i.handler(entity)); err != nil {
+			return fmt.Errorf("subscribe to %s: %w", entity.Subject(), err)
+		}
+	}
+	return nil
+}
+
+// handler evicts the entities of the events of hooks.PublishEntityChangeHook and of the outbox
+func (i *Invalidator) handler(entity Entity) pubsub.Handler {
+	return func(ctx context.Context, msg pubsub.Message) (any, error) {
+		var event hooks.EntityEvent
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			return nil, err
+		}
+
+		ids := eventIDs(event)
+		if err := i.invalidate(ctx, entity, ids); err != nil {
+			i.log.GetWrappedLogger(ctx).Warn("Cache invalidation failed",
+				zap.String("subject", entity.Subject()),
+				zap.Strings("ids", ids),
+				zap.Error(err))
+			return nil, err
+		}
+
+		i.log.GetWrappedLogger(ctx).Debug("Cache invalidated",
+			zap.String("subject", entity.Subject()),
+			zap.String("action", string(event.Action)),
+			zap.Strings("ids", ids))
+		return nil, nil
+	}
+}
+
+// eventIDs returns the ids of the event data, a JSON array once decoded
+func eventIDs(event hooks.EntityEvent) []string {
+	values, _ := event.Data["ids"].([]any)
+
+	ids := make([]string, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func expand(s, id string) string {
+	return strings.ReplaceAll(s, IDPlaceholder, id)
+}
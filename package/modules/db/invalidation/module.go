@@ -0,0 +1,111 @@
+package invalidation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/cache"
+	clocal "github.com/gianglt2198/federation-go/package/infras/cache/local"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+)
+
+// EntityGroup is the fx group of the entities invalidated by the Module
+const EntityGroup = "cache_entities"
+
+// Module evicts the cached entities of the service on their change events
+var Module = fx.Module("cache.invalidation",
+	fx.Provide(NewCacheInvalidator),
+	fx.Invoke(RunInvalidator),
+)
+
+// AsEntity annotates an Entity constructor so that it is registered in the entities group
+func AsEntity(constructor any) any {
+	return fx.Annotate(
+		constructor,
+		fx.ResultTags(fmt.Sprintf(`group:"%s"`, EntityGroup)),
+	)
+}
+
+type InvalidatorParams struct {
+	fx.In
+
+	Log      *logging.Logger
+	Cache    cache.Cache `optional:"true"`
+	Entities []Entity    `group:"cache_entities"`
+}
+
+// NewCacheInvalidator returns nil when the service has no cache
+func NewCacheInvalidator(params InvalidatorParams) *Invalidator {
+	if params.Cache == nil {
+		if len(params.Entities) > 0 {
+			params.Log.Warn("Cache invalidation disabled, neither Redis nor the local cache is enabled")
+		}
+		return nil
+	}
+	return NewInvalidator(params.Cache, params.Log, params.Entities...)
+}
+
+type RunInvalidatorParams struct {
+	fx.In
+
+	Lc         fx.Lifecycle
+	AppConfig  config.AppConfig
+	NATSConfig config.NATSConfig
+
+	Log         *logging.Logger
+	Cache       cache.Cache            `optional:"true"`
+	Invalidator *Invalidator           `optional:"true"`
+	Client      pubsub.Client          `optional:"true"`
+	Subscriber  pubsub.QueueSubscriber `optional:"true"`
+}
+
+// RunInvalidator subscribes to the change events once the app starts. A single replica handles each event
+// of a cache shared through Redis, the tiered caches evicting the local copies of the others,
+// while every replica handles the events of an in process cache.
+func RunInvalidator(params RunInvalidatorParams) error {
+	if params.Invalidator == nil || !params.NATSConfig.Enabled {
+		return nil
+	}
+
+	var subscribe func(ctx context.Context, subject string, handler pubsub.Handler) error
+	if _, ok := params.Cache.(*clocal.Local); ok {
+		if params.Client == nil {
+			return errors.New("cache invalidation: no pubsub client to subscribe with")
+		}
+		subscribe = func(ctx context.Context, subject string, handler pubsub.Handler) error {
+			params.Client.Subscribe(ctx, subject, handler)
+			return nil
+		}
+	} else {
+		if params.Subscriber == nil {
+			return errors.New("cache invalidation: no pubsub queue subscriber to subscribe with")
+		}
+		group := params.AppConfig.Name + ".cache"
+		subscribe = func(ctx context.Context, subject string, handler pubsub.Handler) error {
+			return params.Subscriber.QueueSubscribe(ctx, subject, group, handler)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	params.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			if err := params.Invalidator.Subscribe(ctx, subscribe); err != nil {
+				return err
+			}
+			params.Log.Info("Cache invalidation subscribed", zap.Int("entities", len(params.Invalidator.Entities())))
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"entgo.io/contrib/entgql"
 	"entgo.io/ent"
+	"entgo.io/ent/schema"
 	"entgo.io/ent/schema/field"
 	"entgo.io/ent/schema/mixin"
 
@@ -25,6 +26,20 @@ type AuthorMixin struct {
 	mixin.Schema
 }
 
+// AuthorAnnotation marks the schemas using the AuthorMixin. The author template generates
+// CreatedByUser and UpdatedByUser methods returning the Reference type of the graphql module,
+// which gqlgen binds to the createdByUser and updatedByUser federation fields.
+type AuthorAnnotation struct {
+	Reference string `json:"reference,omitempty"`
+}
+
+// DefaultAuthorReference is the account subgraph entity referenced by the author fields
+const DefaultAuthorReference = "UserEntity"
+
+func (AuthorAnnotation) Name() string {
+	return "Author"
+}
+
 type (
 	SetUpdatedByKey struct{}
 	SetCreatedByKey struct{}
@@ -64,6 +79,12 @@ func (AuthorMixin) Fields() []ent.Field {
 	}
 }
 
+func (AuthorMixin) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		AuthorAnnotation{Reference: DefaultAuthorReference},
+	}
+}
+
 // SkipSetUpdatedBy returns a new context that skips the soft-delete interceptor/mutators.
 func SkipSetUpdatedBy(parent context.Context) context.Context {
 	return context.WithValue(parent, SetUpdatedByKey{}, true)
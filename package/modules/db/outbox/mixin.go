@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"entgo.io/ent"
+	entmixin "entgo.io/ent/schema/mixin"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/modules/db/hooks"
+)
+
+// Mixin stores the change events of the schema entities in the outbox, in the transaction of the mutation.
+// A rolled back mutation leaves no event behind, the relay publishes the committed ones.
+// The hooks need a transaction: the client is built on a Driver, which runs a mutation outside of an ent
+// transaction and its events in one.
+type Mixin struct {
+	entmixin.Schema
+
+	// Service prefixes the topics, like the serviceName of hooks.PublishEntityChangeHook
+	Service string
+}
+
+func (o Mixin) Hooks() []ent.Hook {
+	return []ent.Hook{
+		Hook(o.Service),
+	}
+}
+
+// Hook is the client hook version of the Mixin, replacing hooks.PublishEntityChangeHook
+// for the schemas which do not use the Mixin
+func Hook(serviceName string) ent.Hook {
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			exec, ok := m.(Execer)
+			if !ok {
+				return nil, fmt.Errorf("outbox: mutation %s does not support ExecContext, enable the sql/execquery feature", m.Type())
+			}
+
+			return inTx(ctx, func(ctx context.Context) (ent.Value, error) {
+				value, event, err := hooks.MutateWithEntityEvent(ctx, m, next)
+				if err != nil || event == nil {
+					return value, err
+				}
+
+				payload, err := json.Marshal(event)
+				if err != nil {
+					return nil, fmt.Errorf("outbox: marshal %s event: %w", m.Type(), err)
+				}
+
+				if _, err := Write(ctx, exec, hooks.EntityEventSubject(serviceName, m.Type()), payload); err != nil {
+					return nil, fmt.Errorf("outbox: write %s event: %w", m.Type(), err)
+				}
+
+				return value, nil
+			})
+		})
+	}
+}
+
+// FederationHook replaces hooks.PublishFederationEventHook, it writes the federation events
+// of the gateway subscriptions to the outbox in the transaction of the mutation
+func FederationHook(log *logging.Logger, entities []config.EntityEventConfig) ent.Hook {
+	events := hooks.NewFederationEvents(entities)
+
+	return func(next ent.Mutator) ent.Mutator {
+		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+			exec, ok := m.(Execer)
+			if !ok {
+				return nil, fmt.Errorf("outbox: mutation %s does not support ExecContext, enable the sql/execquery feature", m.Type())
+			}
+
+			return inTx(ctx, func(ctx context.Context) (ent.Value, error) {
+				value, messages, err := events.Mutate(ctx, m, next, log)
+				if err != nil || len(messages) == 0 {
+					return value, err
+				}
+
+				for _, message := range messages {
+					payload, err := json.Marshal(message.Event)
+					if err != nil {
+						return nil, fmt.Errorf("outbox: marshal %s federation event: %w", m.Type(), err)
+					}
+
+					if _, err := Write(ctx, exec, message.Subject, payload); err != nil {
+						return nil, fmt.Errorf("outbox: write %s federation event: %w", m.Type(), err)
+					}
+				}
+
+				return value, nil
+			})
+		})
+	}
+}
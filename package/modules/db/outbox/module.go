@@ -0,0 +1,129 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	"github.com/gianglt2198/federation-go/package/modules/queue"
+	"github.com/gianglt2198/federation-go/package/modules/scheduler"
+)
+
+// RelayTaskName is the queue task relaying the outbox events
+const RelayTaskName = "outbox:relay"
+
+type RelayPayload struct{}
+
+var NewRelayTask = queue.NewTask[RelayPayload](RelayTaskName)
+
+// Module relays the outbox of the service, it expects the *sql.DB of the ent client
+var Module = fx.Module("outbox",
+	fx.Provide(NewOutboxRelay),
+	fx.Provide(fx.Annotate(NewRelayHandler, fx.ResultTags(`group:"processor"`))),
+	fx.Invoke(RunRelay),
+)
+
+type RelayParams struct {
+	fx.In
+
+	DatabaseConfig config.DatabaseConfig
+	NATSConfig     config.NATSConfig
+
+	Log       *logging.Logger
+	DB        *sql.DB
+	Publisher pubsub.Publisher
+}
+
+// NewOutboxRelay returns nil when the outbox is disabled, or when there is no NATS to publish to
+func NewOutboxRelay(params RelayParams) *Relay {
+	if !params.DatabaseConfig.Outbox.Enabled {
+		return nil
+	}
+	if !params.NATSConfig.Enabled {
+		params.Log.Warn("Outbox enabled without NATS, the events are kept in the outbox")
+		return nil
+	}
+
+	if !params.NATSConfig.JetStream.Enabled {
+		params.Log.Warn("Outbox relayed through core NATS, the events are not redelivered to the consumers missing them: capture their topics in JetStream")
+	}
+
+	return NewRelay(params.DB, params.Publisher, params.Log, params.DatabaseConfig.Outbox)
+}
+
+func NewRelayHandler(relay *Relay) *queue.ProcessorHandler {
+	return queue.Handler(RelayTaskName, func(ctx context.Context, _ *RelayPayload, _ *asynq.Task) error {
+		if relay == nil {
+			return nil
+		}
+		_, err := relay.Relay(ctx)
+		return err
+	})
+}
+
+type RunRelayParams struct {
+	fx.In
+
+	Lc              fx.Lifecycle
+	AppConfig       config.AppConfig
+	QueueConfig     config.QueueConfig
+	SchedulerConfig config.SchedulerConfig
+
+	Log       *logging.Logger
+	Relay     *Relay
+	Scheduler *scheduler.Scheduler
+}
+
+// RunRelay schedules the relay on the queue when it runs, a single replica relays at a time.
+// Otherwise each replica relays in a goroutine, the pending events being locked per batch.
+func RunRelay(params RunRelayParams) error {
+	relay := params.Relay
+	if relay == nil {
+		return nil
+	}
+
+	if params.QueueConfig.Enabled && params.SchedulerConfig.Enabled && params.Scheduler != nil {
+		interval := relay.cfg.Interval
+		task := NewRelayTask(&RelayPayload{},
+			asynq.Queue(params.AppConfig.Name),
+			asynq.Unique(interval),
+			asynq.MaxRetry(0),
+		)
+		if err := scheduler.Schedule(fmt.Sprintf("@every %s", interval), task)(params.Scheduler); err != nil {
+			return fmt.Errorf("outbox: schedule relay: %w", err)
+		}
+
+		params.Log.Info("Outbox relay scheduled", zap.Duration("interval", interval))
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	params.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				relay.Run(ctx)
+			}()
+			params.Log.Info("Outbox relay running", zap.Duration("interval", relay.cfg.Interval))
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+
+	return nil
+}
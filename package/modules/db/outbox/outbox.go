@@ -0,0 +1,56 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gianglt2198/federation-go/package/utils"
+)
+
+// TableName is the table of the events waiting to be published
+const TableName = "outbox_events"
+
+// Schema creates the outbox table, the services using the outbox add it to their migrations
+const Schema = `CREATE TABLE "outbox_events" (
+  "id" character varying NOT NULL,
+  "topic" character varying NOT NULL,
+  "payload" bytea NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "last_error" text NULL,
+  "published_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+CREATE INDEX "outbox_events_pending" ON "outbox_events" ("created_at") WHERE "published_at" IS NULL;`
+
+const idSize = 24
+
+// Execer runs a statement, the mutations generated with the sql/execquery feature
+// implement it with the driver of their transaction
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Event is a stored event, its ID is the dedup ID of the published message
+type Event struct {
+	ID       string
+	Topic    string
+	Payload  []byte
+	Attempts int
+}
+
+// Write stores an event to publish on the topic and returns its ID
+func Write(ctx context.Context, exec Execer, topic string, payload []byte) (string, error) {
+	id := utils.NewID(idSize, "evt_")
+
+	_, err := exec.ExecContext(ctx,
+		`INSERT INTO "outbox_events" ("id", "topic", "payload", "created_at") VALUES ($1, $2, $3, $4)`,
+		id, topic, payload, time.Now().UTC(),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
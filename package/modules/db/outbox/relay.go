@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	nats "github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+)
+
+const (
+	defaultInterval    = 5 * time.Second
+	defaultBatchSize   = 100
+	defaultMaxAttempts = 10
+	defaultRetention   = 24 * time.Hour
+)
+
+// Relay publishes the outbox events: an event is marked as published only once the publisher accepted it,
+// and published again otherwise. The delivery is at least once up to the NATS client only: core NATS does not
+// persist the messages, those published while a subscriber is away, or lost on a disconnection, are not redelivered.
+// The topics must be captured by a JetStream stream (nats.jetstream.subjects) for an at-least-once delivery to the
+// consumers, the event ID is then sent as the Nats-Msg-Id header so that JetStream drops the duplicates.
+// The pending events are locked with SKIP LOCKED, the replicas can relay at the same time.
+type Relay struct {
+	db        *sql.DB
+	publisher pubsub.Publisher
+	log       *logging.Logger
+	cfg       config.OutboxConfig
+}
+
+func NewRelay(db *sql.DB, publisher pubsub.Publisher, log *logging.Logger, cfg config.OutboxConfig) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultRetention
+	}
+
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		log:       log,
+		cfg:       cfg,
+	}
+}
+
+// Relay publishes a batch of pending events and returns the number of published events
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: begin relay: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	events, err := pendingEvents(ctx, tx, r.cfg.MaxAttempts, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		err := r.publisher.Publish(ctx, event.Topic, event.Payload, map[string]string{nats.MsgIdHdr: event.ID})
+		if err != nil {
+			r.log.GetWrappedLogger(ctx).Warn("Failed to relay outbox event",
+				zap.String("id", event.ID), zap.String("topic", event.Topic), zap.Int("attempts", event.Attempts+1), zap.Error(err))
+			if event.Attempts+1 >= r.cfg.MaxAttempts {
+				r.log.GetWrappedLogger(ctx).Error("Outbox event reached the max attempts", zap.String("id", event.ID), zap.String("topic", event.Topic))
+			}
+
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE "outbox_events" SET "attempts" = "attempts" + 1, "last_error" = $2 WHERE "id" = $1`,
+				event.ID, err.Error(),
+			); err != nil {
+				return published, fmt.Errorf("outbox: record failed attempt: %w", err)
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE "outbox_events" SET "published_at" = $2, "attempts" = "attempts" + 1, "last_error" = NULL WHERE "id" = $1`,
+			event.ID, time.Now().UTC(),
+		); err != nil {
+			return published, fmt.Errorf("outbox: mark published: %w", err)
+		}
+		published++
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM "outbox_events" WHERE "published_at" < $1`,
+		time.Now().UTC().Add(-r.cfg.Retention),
+	); err != nil {
+		return published, fmt.Errorf("outbox: purge published events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return published, fmt.Errorf("outbox: commit relay: %w", err)
+	}
+
+	return published, nil
+}
+
+// Run relays the events every interval until the context is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// a full batch means more events are waiting
+			for {
+				published, err := r.Relay(ctx)
+				if err != nil && !errors.Is(err, context.Canceled) {
+					r.log.Error("Outbox relay failed", zap.Error(err))
+				}
+				if err != nil || published < r.cfg.BatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+func pendingEvents(ctx context.Context, tx *sql.Tx, maxAttempts, batchSize int) ([]Event, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT "id", "topic", "payload", "attempts" FROM "outbox_events"
+		WHERE "published_at" IS NULL AND "attempts" < $1
+		ORDER BY "created_at"
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`,
+		maxAttempts, batchSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("outbox: select pending events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0, batchSize)
+	for rows.Next() {
+		var event Event
+		if err := rows.Scan(&event.ID, &event.Topic, &event.Payload, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("outbox: scan pending event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
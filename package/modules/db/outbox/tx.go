@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect/sql"
+)
+
+type txKey struct{}
+
+// hookTx is the transaction of the outbox hooks, begun by the first statement run with their context
+type hookTx struct {
+	mu sync.Mutex
+	tx *stdsql.Tx
+}
+
+// Driver runs the statements of the outbox hooks in their transaction, the clients using the hooks
+// are built on it. The other statements, and those of an ent transaction, run as with the wrapped driver.
+type Driver struct {
+	*sql.Driver
+}
+
+func NewDriver(drv *sql.Driver) *Driver {
+	return &Driver{Driver: drv}
+}
+
+func (d *Driver) conn(ctx context.Context) (sql.Conn, error) {
+	h, ok := ctx.Value(txKey{}).(*hookTx)
+	if !ok {
+		return d.Conn, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.tx == nil {
+		tx, err := d.DB().BeginTx(ctx, nil)
+		if err != nil {
+			return sql.Conn{}, fmt.Errorf("outbox: begin transaction: %w", err)
+		}
+		h.tx = tx
+	}
+
+	return sql.Conn{ExecQuerier: h.tx}, nil
+}
+
+func (d *Driver) Exec(ctx context.Context, query string, args, v any) error {
+	conn, err := d.conn(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Exec(ctx, query, args, v)
+}
+
+func (d *Driver) Query(ctx context.Context, query string, args, v any) error {
+	conn, err := d.conn(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Query(ctx, query, args, v)
+}
+
+func (d *Driver) ExecContext(ctx context.Context, query string, args ...any) (stdsql.Result, error) {
+	conn, err := d.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.ExecContext(ctx, query, args...)
+}
+
+func (d *Driver) QueryContext(ctx context.Context, query string, args ...any) (*stdsql.Rows, error) {
+	conn, err := d.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.QueryContext(ctx, query, args...)
+}
+
+// inTx runs the mutation and the write of its events in a transaction of the Driver, committed when both succeed.
+// A mutation of an ent transaction does not run on the Driver, its events are written in that transaction.
+func inTx(ctx context.Context, mutate func(context.Context) (ent.Value, error)) (value ent.Value, err error) {
+	if _, ok := ctx.Value(txKey{}).(*hookTx); ok {
+		return mutate(ctx)
+	}
+
+	h := &hookTx{}
+	defer func() {
+		if h.tx == nil {
+			return
+		}
+		if r := recover(); r != nil {
+			_ = h.tx.Rollback()
+			panic(r)
+		}
+		if err != nil {
+			err = errors.Join(err, h.tx.Rollback())
+			return
+		}
+		if err = h.tx.Commit(); err != nil {
+			value, err = nil, fmt.Errorf("outbox: commit transaction: %w", err)
+		}
+	}()
+
+	return mutate(context.WithValue(ctx, txKey{}, h))
+}
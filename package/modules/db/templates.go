@@ -26,6 +26,9 @@ var (
 
 	// Edge Template
 	EdgeTemplate = gen.MustParse(gen.NewTemplate("edge.tmpl").ParseFS(templates, "templates/edge.tmpl"))
+
+	// Author Template, federation references of the AuthorMixin users
+	AuthorTemplate = gen.MustParse(gen.NewTemplate("author.tmpl").ParseFS(templates, "templates/author.tmpl"))
 )
 
 func RepositoryExtention() entc.Option {
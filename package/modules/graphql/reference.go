@@ -0,0 +1,10 @@
+package graphql
+
+// UserEntity is the federation reference to a user owned by the account subgraph.
+// Only the key is known by the other subgraphs, the gateway resolves the remaining
+// fields through batched _entities lookups.
+type UserEntity struct {
+	ID string `json:"id"`
+}
+
+func (UserEntity) IsEntity() {}
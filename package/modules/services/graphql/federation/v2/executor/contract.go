@@ -0,0 +1,290 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astprinter"
+)
+
+const tagDirectiveName = "tag"
+
+// Contract is a variant of the client schema which hides the elements tagged with one of the excluded tags.
+// A contract without excluded tags exposes the whole client schema.
+type Contract struct {
+	Name        string
+	ExcludeTags []string
+}
+
+// Tags maps schema coordinates, "Type" or "Type.field", to the names of their @tag directives
+type Tags map[string][]string
+
+// CollectTags reads the @tag directives of the subgraph SDLs.
+// The composition strips @tag from the supergraph, so tags must be taken from the subgraphs.
+func CollectTags(sdls ...string) (Tags, error) {
+	tags := Tags{}
+
+	for _, sdl := range sdls {
+		doc, report := astparser.ParseGraphqlDocumentString(sdl)
+		if report.HasErrors() {
+			return nil, fmt.Errorf("failed to parse subgraph schema: %w", report)
+		}
+
+		for _, node := range doc.RootNodes {
+			typeName := doc.NodeNameString(node)
+			tags.add(&doc, typeName, doc.NodeDirectives(node))
+
+			for _, ref := range fieldRefs(&doc, node) {
+				tags.add(&doc, typeName+"."+doc.FieldDefinitionNameString(ref), doc.FieldDefinitions[ref].Directives.Refs)
+			}
+			for _, ref := range inputFieldRefs(&doc, node) {
+				tags.add(&doc, typeName+"."+doc.InputValueDefinitionNameString(ref), doc.InputValueDefinitions[ref].Directives.Refs)
+			}
+			for _, ref := range enumValueRefs(&doc, node) {
+				tags.add(&doc, typeName+"."+doc.EnumValueDefinitionNameString(ref), doc.EnumValueDefinitions[ref].Directives.Refs)
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+func (t Tags) add(doc *ast.Document, coordinate string, directives []int) {
+	for _, directive := range directives {
+		if doc.DirectiveNameString(directive) != tagDirectiveName {
+			continue
+		}
+
+		value, ok := doc.DirectiveArgumentValueByName(directive, []byte("name"))
+		if !ok || value.Kind != ast.ValueKindString {
+			continue
+		}
+
+		name := doc.StringValueContentString(value.Ref)
+		if !slices.Contains(t[coordinate], name) {
+			t[coordinate] = append(t[coordinate], name)
+		}
+	}
+}
+
+// excluded returns the coordinates tagged with at least one of the given tags
+func (t Tags) excluded(excludeTags []string) map[string]struct{} {
+	coordinates := make(map[string]struct{})
+	for coordinate, names := range t {
+		for _, name := range names {
+			if slices.Contains(excludeTags, name) {
+				coordinates[coordinate] = struct{}{}
+				break
+			}
+		}
+	}
+	return coordinates
+}
+
+// FilterSchema removes the excluded coordinates from the SDL, together with the fields
+// referencing removed types and the types left without any field.
+func (t Tags) FilterSchema(sdl string, contract Contract) (string, error) {
+	excluded := t.excluded(contract.ExcludeTags)
+	if len(excluded) == 0 {
+		return sdl, nil
+	}
+
+	doc, report := astparser.ParseGraphqlDocumentString(sdl)
+	if report.HasErrors() {
+		return "", fmt.Errorf("failed to parse client schema: %w", report)
+	}
+
+	removedTypes := make(map[string]struct{})
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, node := range doc.RootNodes {
+			if node.Kind == ast.NodeKindUnknown {
+				continue
+			}
+
+			typeName := doc.NodeNameString(node)
+			if _, ok := removedTypes[typeName]; ok {
+				continue
+			}
+
+			if _, ok := excluded[typeName]; ok || filterNode(&doc, node, typeName, excluded, removedTypes) {
+				removedTypes[typeName] = struct{}{}
+				changed = true
+			}
+		}
+	}
+
+	for i := len(doc.RootNodes) - 1; i >= 0; i-- {
+		node := doc.RootNodes[i]
+		if node.Kind == ast.NodeKindUnknown {
+			continue
+		}
+		if _, ok := removedTypes[doc.NodeNameString(node)]; ok {
+			doc.RemoveRootNode(node)
+		}
+	}
+
+	return astprinter.PrintString(&doc)
+}
+
+// filterNode drops the excluded members of a type and reports whether the type became empty
+func filterNode(doc *ast.Document, node ast.Node, typeName string, excluded, removedTypes map[string]struct{}) bool {
+	isExcluded := func(member string, typeRef int) bool {
+		if _, ok := excluded[typeName+"."+member]; ok {
+			return true
+		}
+		if typeRef == ast.InvalidRef {
+			return false
+		}
+		_, ok := removedTypes[doc.ResolveTypeNameString(typeRef)]
+		return ok
+	}
+
+	switch node.Kind {
+	case ast.NodeKindObjectTypeDefinition, ast.NodeKindObjectTypeExtension, ast.NodeKindInterfaceTypeDefinition, ast.NodeKindInterfaceTypeExtension:
+		refs := fieldRefs(doc, node)
+		kept := slices.DeleteFunc(slices.Clone(refs), func(ref int) bool {
+			return isExcluded(doc.FieldDefinitionNameString(ref), doc.FieldDefinitions[ref].Type) ||
+				slices.ContainsFunc(doc.FieldDefinitions[ref].ArgumentsDefinition.Refs, func(arg int) bool {
+					_, ok := removedTypes[doc.ResolveTypeNameString(doc.InputValueDefinitions[arg].Type)]
+					return ok
+				})
+		})
+		interfaces := slices.DeleteFunc(slices.Clone(implementedInterfaceRefs(doc, node)), func(ref int) bool {
+			_, ok := removedTypes[doc.TypeNameString(ref)]
+			return ok
+		})
+		setFieldRefs(doc, node, kept, interfaces)
+		return len(refs) > 0 && len(kept) == 0
+	case ast.NodeKindInputObjectTypeDefinition, ast.NodeKindInputObjectTypeExtension:
+		refs := inputFieldRefs(doc, node)
+		kept := slices.DeleteFunc(slices.Clone(refs), func(ref int) bool {
+			return isExcluded(doc.InputValueDefinitionNameString(ref), doc.InputValueDefinitions[ref].Type)
+		})
+		setInputFieldRefs(doc, node, kept)
+		return len(refs) > 0 && len(kept) == 0
+	case ast.NodeKindEnumTypeDefinition, ast.NodeKindEnumTypeExtension:
+		refs := enumValueRefs(doc, node)
+		kept := slices.DeleteFunc(slices.Clone(refs), func(ref int) bool {
+			return isExcluded(doc.EnumValueDefinitionNameString(ref), ast.InvalidRef)
+		})
+		setEnumValueRefs(doc, node, kept)
+		return len(refs) > 0 && len(kept) == 0
+	case ast.NodeKindUnionTypeDefinition, ast.NodeKindUnionTypeExtension:
+		refs := unionMemberRefs(doc, node)
+		kept := slices.DeleteFunc(slices.Clone(refs), func(ref int) bool {
+			_, ok := removedTypes[doc.TypeNameString(ref)]
+			return ok
+		})
+		setUnionMemberRefs(doc, node, kept)
+		return len(refs) > 0 && len(kept) == 0
+	}
+
+	return false
+}
+
+func fieldRefs(doc *ast.Document, node ast.Node) []int {
+	switch node.Kind {
+	case ast.NodeKindObjectTypeDefinition:
+		return doc.ObjectTypeDefinitions[node.Ref].FieldsDefinition.Refs
+	case ast.NodeKindObjectTypeExtension:
+		return doc.ObjectTypeExtensions[node.Ref].FieldsDefinition.Refs
+	case ast.NodeKindInterfaceTypeDefinition:
+		return doc.InterfaceTypeDefinitions[node.Ref].FieldsDefinition.Refs
+	case ast.NodeKindInterfaceTypeExtension:
+		return doc.InterfaceTypeExtensions[node.Ref].FieldsDefinition.Refs
+	}
+	return nil
+}
+
+func implementedInterfaceRefs(doc *ast.Document, node ast.Node) []int {
+	switch node.Kind {
+	case ast.NodeKindObjectTypeDefinition:
+		return doc.ObjectTypeDefinitions[node.Ref].ImplementsInterfaces.Refs
+	case ast.NodeKindObjectTypeExtension:
+		return doc.ObjectTypeExtensions[node.Ref].ImplementsInterfaces.Refs
+	case ast.NodeKindInterfaceTypeDefinition:
+		return doc.InterfaceTypeDefinitions[node.Ref].ImplementsInterfaces.Refs
+	case ast.NodeKindInterfaceTypeExtension:
+		return doc.InterfaceTypeExtensions[node.Ref].ImplementsInterfaces.Refs
+	}
+	return nil
+}
+
+func setFieldRefs(doc *ast.Document, node ast.Node, fields, interfaces []int) {
+	switch node.Kind {
+	case ast.NodeKindObjectTypeDefinition:
+		doc.ObjectTypeDefinitions[node.Ref].FieldsDefinition.Refs = fields
+		doc.ObjectTypeDefinitions[node.Ref].ImplementsInterfaces.Refs = interfaces
+	case ast.NodeKindObjectTypeExtension:
+		doc.ObjectTypeExtensions[node.Ref].FieldsDefinition.Refs = fields
+		doc.ObjectTypeExtensions[node.Ref].ImplementsInterfaces.Refs = interfaces
+	case ast.NodeKindInterfaceTypeDefinition:
+		doc.InterfaceTypeDefinitions[node.Ref].FieldsDefinition.Refs = fields
+		doc.InterfaceTypeDefinitions[node.Ref].ImplementsInterfaces.Refs = interfaces
+	case ast.NodeKindInterfaceTypeExtension:
+		doc.InterfaceTypeExtensions[node.Ref].FieldsDefinition.Refs = fields
+		doc.InterfaceTypeExtensions[node.Ref].ImplementsInterfaces.Refs = interfaces
+	}
+}
+
+func inputFieldRefs(doc *ast.Document, node ast.Node) []int {
+	switch node.Kind {
+	case ast.NodeKindInputObjectTypeDefinition:
+		return doc.InputObjectTypeDefinitions[node.Ref].InputFieldsDefinition.Refs
+	case ast.NodeKindInputObjectTypeExtension:
+		return doc.InputObjectTypeExtensions[node.Ref].InputFieldsDefinition.Refs
+	}
+	return nil
+}
+
+func setInputFieldRefs(doc *ast.Document, node ast.Node, refs []int) {
+	switch node.Kind {
+	case ast.NodeKindInputObjectTypeDefinition:
+		doc.InputObjectTypeDefinitions[node.Ref].InputFieldsDefinition.Refs = refs
+	case ast.NodeKindInputObjectTypeExtension:
+		doc.InputObjectTypeExtensions[node.Ref].InputFieldsDefinition.Refs = refs
+	}
+}
+
+func enumValueRefs(doc *ast.Document, node ast.Node) []int {
+	switch node.Kind {
+	case ast.NodeKindEnumTypeDefinition:
+		return doc.EnumTypeDefinitions[node.Ref].EnumValuesDefinition.Refs
+	case ast.NodeKindEnumTypeExtension:
+		return doc.EnumTypeExtensions[node.Ref].EnumValuesDefinition.Refs
+	}
+	return nil
+}
+
+func setEnumValueRefs(doc *ast.Document, node ast.Node, refs []int) {
+	switch node.Kind {
+	case ast.NodeKindEnumTypeDefinition:
+		doc.EnumTypeDefinitions[node.Ref].EnumValuesDefinition.Refs = refs
+	case ast.NodeKindEnumTypeExtension:
+		doc.EnumTypeExtensions[node.Ref].EnumValuesDefinition.Refs = refs
+	}
+}
+
+func unionMemberRefs(doc *ast.Document, node ast.Node) []int {
+	switch node.Kind {
+	case ast.NodeKindUnionTypeDefinition:
+		return doc.UnionTypeDefinitions[node.Ref].UnionMemberTypes.Refs
+	case ast.NodeKindUnionTypeExtension:
+		return doc.UnionTypeExtensions[node.Ref].UnionMemberTypes.Refs
+	}
+	return nil
+}
+
+func setUnionMemberRefs(doc *ast.Document, node ast.Node, refs []int) {
+	switch node.Kind {
+	case ast.NodeKindUnionTypeDefinition:
+		doc.UnionTypeDefinitions[node.Ref].UnionMemberTypes.Refs = refs
+	case ast.NodeKindUnionTypeExtension:
+		doc.UnionTypeExtensions[node.Ref].UnionMemberTypes.Refs = refs
+	}
+}
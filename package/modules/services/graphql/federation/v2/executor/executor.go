@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"sort"
 
 	lru "github.com/hashicorp/golang-lru"
 
@@ -17,6 +18,8 @@ import (
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/pool"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/variablesvalidation"
+
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
 )
 
 type Executor struct {
@@ -30,19 +33,44 @@ type Executor struct {
 	Resolver                 *resolve.Resolver
 	RenameTypeNames          []resolve.RenameTypeName
 	executionPlanCache       *lru.Cache
+	operations               *lru.Cache
+	operationStore           OperationStore
+	contract                 string // empty for the full client schema
+	plugins                  plugin.Chain
+	contracts                map[string]*Executor
 	apolloCompatibilityFlags apollocompatibility.Flags
 }
 
+// Contract returns the executor of the named client schema variant
+func (e *Executor) Contract(name string) (*Executor, bool) {
+	contract, ok := e.contracts[name]
+	return contract, ok
+}
+
+// Contracts returns the names of the client schema variants, sorted
+func (e *Executor) Contracts() []string {
+	names := make([]string, 0, len(e.contracts))
+	for name := range e.contracts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (e *Executor) Execute(ctx context.Context, operation *graphql.Request, writer resolve.SubscriptionResponseWriter) error {
 	if err := e.normalizeOperation(operation); err != nil {
 		return err
 	}
 
+	if err := e.receiveOperation(ctx, operation); err != nil {
+		return err
+	}
+
 	execContext := newInternalExecutionContext()
 	execContext.prepare(ctx, operation.Variables, operation.InternalRequest())
 
 	var report operationreport.Report
-	cachedPlan := e.getCachedPlan(execContext, operation.Document(), e.RouterSchema, operation.OperationName, &report)
+	cachedPlan := e.getCachedPlan(execContext, operation, &report)
 	if report.HasErrors() {
 		return report
 	}
@@ -59,6 +87,25 @@ func (e *Executor) Execute(ctx context.Context, operation *graphql.Request, writ
 }
 
 func (e *Executor) normalizeOperation(operation *graphql.Request) error {
+	if err := e.normalizeDocument(operation); err != nil {
+		return err
+	}
+
+	// Validate user-supplied and extracted variables against the operation.
+	if len(operation.Variables) > 0 && operation.Variables[0] == '{' {
+		validator := variablesvalidation.NewVariablesValidator(variablesvalidation.VariablesValidatorOptions{
+			ApolloCompatibilityFlags: e.apolloCompatibilityFlags,
+		})
+		if err := validator.Validate(operation.Document(), e.RouterSchema, operation.Variables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeDocument normalizes and validates the operation document, without its variables.
+// The plans only depend on the document, so the warmup plans the operations without variables.
+func (e *Executor) normalizeDocument(operation *graphql.Request) error {
 	normalize := !operation.IsNormalized()
 	if normalize {
 		// Normalize the operation, but extract variables later so ValidateForSchema can return correct error messages for bad arguments.
@@ -93,51 +140,81 @@ func (e *Executor) normalizeOperation(operation *graphql.Request) error {
 			return result.Errors
 		}
 	}
+	return nil
+}
 
-	// Validate user-supplied and extracted variables against the operation.
-	if len(operation.Variables) > 0 && operation.Variables[0] == '{' {
-		validator := variablesvalidation.NewVariablesValidator(variablesvalidation.VariablesValidatorOptions{
-			ApolloCompatibilityFlags: e.apolloCompatibilityFlags,
-		})
-		if err := validator.Validate(operation.Document(), e.RouterSchema, operation.Variables); err != nil {
-			return err
-		}
+// receiveOperation hands the normalized operation to the plugins, variables may be rewritten by them.
+func (e *Executor) receiveOperation(ctx context.Context, operation *graphql.Request) error {
+	if len(e.plugins) == 0 {
+		return nil
+	}
+
+	op := &plugin.Operation{
+		Name:      operation.OperationName,
+		Document:  operation.Document(),
+		Variables: operation.Variables,
+		Request:   operation,
+	}
+	if err := e.plugins.OnOperationReceived(ctx, op); err != nil {
+		return err
 	}
+
+	operation.Variables = op.Variables
 	return nil
 }
 
-func (e *Executor) getCachedPlan(ctx *internalExecutionContext, operation, definition *ast.Document, operationName string, report *operationreport.Report) plan.Plan {
-	hash := pool.Hash64.Get()
-	hash.Reset()
-	defer pool.Hash64.Put(hash)
-	err := astprinter.Print(operation, hash)
+func (e *Executor) getCachedPlan(ctx *internalExecutionContext, request *graphql.Request, report *operationreport.Report) plan.Plan {
+	cacheKey, err := planCacheKey(request)
 	if err != nil {
 		report.AddInternalError(err)
 		return nil
 	}
 
-	cacheKey := hash.Sum64()
-
 	if cached, ok := e.executionPlanCache.Get(cacheKey); ok {
 		if p, ok := cached.(plan.Plan); ok {
+			e.trackOperation(ctx.resolveContext.Context(), cacheKey, request)
 			return p
 		}
 	}
 
+	p := e.planOperation(ctx, request, report)
+	if p == nil {
+		return nil
+	}
+
+	e.executionPlanCache.Add(cacheKey, p)
+	e.trackOperation(ctx.resolveContext.Context(), cacheKey, request)
+	return p
+}
+
+// planCacheKey hashes the normalized operation document
+func planCacheKey(request *graphql.Request) (uint64, error) {
+	hash := pool.Hash64.Get()
+	hash.Reset()
+	defer pool.Hash64.Put(hash)
+
+	if err := astprinter.Print(request.Document(), hash); err != nil {
+		return 0, err
+	}
+	return hash.Sum64(), nil
+}
+
+// planOperation plans the normalized operation against the router schema, nil when the report has errors
+func (e *Executor) planOperation(ctx *internalExecutionContext, request *graphql.Request, report *operationreport.Report) plan.Plan {
+	operation, definition := request.Document(), e.RouterSchema
+
 	// TODO: Handle global complexity result
 	// globalComplexityResult, rootFieldStats := operation_complexity.CalculateOperationComplexity(operation, definition, report)
 	// e.PlanConfig.Logger.Info("globalComplexityResult", abstractlogger.Any("globalComplexityResult", globalComplexityResult))
 	// e.PlanConfig.Logger.Info("rootFieldStats", abstractlogger.Any("rootFieldStats", rootFieldStats))
 
 	planner, _ := plan.NewPlanner(e.PlanConfig)
-	planResult := planner.Plan(operation, definition, operationName, report)
+	planResult := planner.Plan(operation, definition, request.OperationName, report)
 	if report.HasErrors() {
 		return nil
 	}
 
-	p := ctx.postProcessor.Process(planResult)
-	e.executionPlanCache.Add(cacheKey, p)
-	return p
+	return ctx.postProcessor.Process(planResult)
 }
 
 func (e *Executor) ExecuteSubscription(ctx context.Context, operation *graphql.Request, writer resolve.SubscriptionResponseWriter, id resolve.SubscriptionIdentifier) (plan.Plan, error) {
@@ -145,11 +222,15 @@ func (e *Executor) ExecuteSubscription(ctx context.Context, operation *graphql.R
 		return nil, err
 	}
 
+	if err := e.receiveOperation(ctx, operation); err != nil {
+		return nil, err
+	}
+
 	execContext := newInternalExecutionContext()
 	execContext.prepare(ctx, operation.Variables, operation.InternalRequest())
 
 	var report operationreport.Report
-	cachedPlan := e.getCachedPlan(execContext, operation.Document(), e.RouterSchema, operation.OperationName, &report)
+	cachedPlan := e.getCachedPlan(execContext, operation, &report)
 	if report.HasErrors() {
 		return nil, report
 	}
@@ -3,10 +3,12 @@ package executor
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	lru "github.com/hashicorp/golang-lru"
 
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	routerCfg "github.com/wundergraph/cosmo/router/pkg/config"
 	pubsub_datasource "github.com/wundergraph/cosmo/router/pkg/pubsub/datasource"
 	"github.com/wundergraph/graphql-go-tools/execution/graphql"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/apollocompatibility"
@@ -22,6 +24,7 @@ import (
 	"github.com/gianglt2198/federation-go/package/infras/pubsub"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/types"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/loader"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/resolver"
 )
 
@@ -36,6 +39,12 @@ type ExecutorConfigurationBuildParams struct {
 	Broker             pubsub.Broker
 	Logger             *logging.Logger
 	Introspection      bool
+	PlanCacheSize      int
+	OperationStore     OperationStore
+	Plugins            []plugin.Plugin
+	// Contracts are the client schema variants, filtered with the subgraph Tags
+	Contracts []Contract
+	Tags      Tags
 }
 
 func (b *ExecutorConfigurationBuilder) Build(ctx context.Context, params ExecutorConfigurationBuildParams) (*Executor, []pubsub_datasource.Provider, error) {
@@ -50,6 +59,7 @@ func (b *ExecutorConfigurationBuilder) Build(ctx context.Context, params Executo
 		Reporter:                           params.Reporter,
 		PropagateSubgraphErrors:            params.RouterEngineConfig.SubgraphErrorPropagation.Enabled,
 		PropagateSubgraphStatusCodes:       params.RouterEngineConfig.SubgraphErrorPropagation.PropagateStatusCodes,
+		SubgraphErrorPropagationMode:       subgraphErrorPropagationMode(params.RouterEngineConfig.SubgraphErrorPropagation.Mode),
 		RewriteSubgraphErrorPaths:          params.RouterEngineConfig.SubgraphErrorPropagation.RewritePaths,
 		OmitSubgraphErrorLocations:         params.RouterEngineConfig.SubgraphErrorPropagation.OmitLocations,
 		OmitSubgraphErrorExtensions:        params.RouterEngineConfig.SubgraphErrorPropagation.OmitExtensions,
@@ -103,6 +113,11 @@ func (b *ExecutorConfigurationBuilder) Build(ctx context.Context, params Executo
 		clientSchemaDefinition = &routerSchemaDefinition
 	}
 
+	// contracts share the data sources but expose their own introspection
+	basePlanConfig := *planConfig
+	basePlanConfig.Fields = slices.Clone(planConfig.Fields)
+	basePlanConfig.DataSources = slices.Clone(planConfig.DataSources)
+
 	if params.Introspection {
 		// by default, the engine doesn't understand how to resolve the __schema and __type queries
 		// we need to add a special datasource for that
@@ -134,7 +149,20 @@ func (b *ExecutorConfigurationBuilder) Build(ctx context.Context, params Executo
 		}
 	}
 
-	executionPlanCache, _ := lru.New(1000)
+	planCacheSize := params.PlanCacheSize
+	if planCacheSize <= 0 {
+		planCacheSize = DefaultPlanCacheSize
+	}
+
+	executionPlanCache, err := lru.New(planCacheSize)
+	if err != nil {
+		return nil, providers, fmt.Errorf("failed to create execution plan cache: %w", err)
+	}
+
+	operations, err := lru.New(planCacheSize)
+	if err != nil {
+		return nil, providers, fmt.Errorf("failed to create operation cache: %w", err)
+	}
 
 	schemaSDL := params.EngineConfig.GraphqlSchema
 
@@ -143,23 +171,115 @@ func (b *ExecutorConfigurationBuilder) Build(ctx context.Context, params Executo
 		return nil, providers, fmt.Errorf("failed to create schema from string: %w", err)
 	}
 
-	return &Executor{
+	exec := &Executor{
 		PlanConfig:         *planConfig,
 		ClientSchema:       clientSchemaDefinition,
 		RouterSchema:       &routerSchemaDefinition,
 		Resolver:           resolver,
 		RenameTypeNames:    renameTypeNames,
 		executionPlanCache: executionPlanCache,
+		operations:         operations,
+		operationStore:     params.OperationStore,
+		plugins:            params.Plugins,
 		apolloCompatibilityFlags: apollocompatibility.Flags{
 			ReplaceInvalidVarError: true,
 		},
 		Schema: schema,
-	}, providers, nil
+	}
+
+	if len(params.Contracts) > 0 {
+		clientSDL := params.EngineConfig.GetGraphqlClientSchema()
+		if clientSDL == "" {
+			clientSDL = schemaSDL
+		}
+
+		exec.contracts = make(map[string]*Executor, len(params.Contracts))
+		for _, contract := range params.Contracts {
+			contractExec, err := b.buildContract(exec, contract, clientSDL, basePlanConfig, &params)
+			if err != nil {
+				return nil, providers, fmt.Errorf("failed to build contract %s: %w", contract.Name, err)
+			}
+			exec.contracts[contract.Name] = contractExec
+		}
+	}
+
+	return exec, providers, nil
+}
+
+// buildContract creates an executor validating operations against the contract schema.
+// It shares the resolver and the router schema of the given executor, plans are cached separately.
+func (b *ExecutorConfigurationBuilder) buildContract(exec *Executor, contract Contract, clientSDL string, planConfig plan.Configuration, params *ExecutorConfigurationBuildParams) (*Executor, error) {
+	contractSDL, err := params.Tags.FilterSchema(clientSDL, contract)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSchema, report := astparser.ParseGraphqlDocumentString(contractSDL)
+	if report.HasErrors() {
+		return nil, fmt.Errorf("failed to parse contract schema: %w", report)
+	}
+	if err := asttransform.MergeDefinitionWithBaseSchema(&clientSchema); err != nil {
+		return nil, fmt.Errorf("failed to merge contract schema with base schema: %w", err)
+	}
+
+	schema, err := graphql.NewSchemaFromString(contractSDL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema from contract: %w", err)
+	}
+
+	planConfig.Fields = slices.Clone(planConfig.Fields)
+	planConfig.DataSources = slices.Clone(planConfig.DataSources)
+
+	if params.Introspection {
+		introspectionFactory, err := introspection_datasource.NewIntrospectionConfigFactory(&clientSchema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create introspection config factory: %w", err)
+		}
+		planConfig.Fields = append(planConfig.Fields, introspectionFactory.BuildFieldConfigurations()...)
+		planConfig.DataSources = append(planConfig.DataSources, introspectionFactory.BuildDataSourceConfigurations()...)
+	}
+
+	planCacheSize := params.PlanCacheSize
+	if planCacheSize <= 0 {
+		planCacheSize = DefaultPlanCacheSize
+	}
+
+	executionPlanCache, err := lru.New(planCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create execution plan cache: %w", err)
+	}
+
+	operations, err := lru.New(planCacheSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create operation cache: %w", err)
+	}
+
+	return &Executor{
+		PlanConfig:               planConfig,
+		ClientSchema:             &clientSchema,
+		RouterSchema:             exec.RouterSchema,
+		Schema:                   schema,
+		Resolver:                 exec.Resolver,
+		RenameTypeNames:          exec.RenameTypeNames,
+		executionPlanCache:       executionPlanCache,
+		operations:               operations,
+		operationStore:           exec.operationStore,
+		contract:                 contract.Name,
+		plugins:                  exec.plugins,
+		apolloCompatibilityFlags: exec.apolloCompatibilityFlags,
+	}, nil
+}
+
+func subgraphErrorPropagationMode(mode routerCfg.SubgraphErrorPropagationMode) resolve.SubgraphErrorPropagationMode {
+	if mode == routerCfg.SubgraphErrorPropagationModePassthrough {
+		return resolve.SubgraphErrorPropagationModePassThrough
+	}
+	return resolve.SubgraphErrorPropagationModeWrapped
 }
 
 func (b *ExecutorConfigurationBuilder) buildPlannerConfiguration(ctx context.Context, params *ExecutorConfigurationBuildParams) (*plan.Configuration, []pubsub_datasource.Provider, error) {
 	// Implementation of the planner configuration building logic
-	factory := resolver.NewDefaultFactoryResolver(ctx, params.Logger, true, params.InstanceData, params.Broker)
+	factory := resolver.NewDefaultFactoryResolver(ctx, params.Logger, true, params.InstanceData, params.Broker, params.Plugins)
 
 	loader := loader.NewLoader(ctx, factory, params.Logger)
 
@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astprinter"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+)
+
+const (
+	DefaultPlanCacheSize  = 1000
+	DefaultPlanWarmupSize = 100
+)
+
+// Operation is an operation text whose execution plan was cached by the executor
+type Operation struct {
+	Hash uint64 `json:"hash"`
+	// Query is the normalized document the plan was built from, its argument literals are extracted
+	// as variables so that the stored operations hold no value sent by the clients
+	Query         string `json:"query"`
+	OperationName string `json:"operationName,omitempty"`
+	// Contract is the client schema variant the operation was planned for, empty for the full schema
+	Contract string `json:"contract,omitempty"`
+	// Hits is the number of plan cache hits when the operation was last saved
+	Hits int64 `json:"hits,omitempty"`
+}
+
+// OperationStore persists planned operations so other executors can warm their plan cache
+type OperationStore interface {
+	// Save stores the operation, again each time its hits double
+	Save(ctx context.Context, operation Operation) error
+	// Load returns up to limit operations, the most used first
+	Load(ctx context.Context, limit int) ([]Operation, error)
+}
+
+type trackedOperation struct {
+	operation Operation
+	hits      atomic.Int64
+}
+
+// trackOperation records the operation behind a cached plan, counting how often the plan is reused.
+// The operation is saved to the store when first seen, then each time its hits double.
+func (e *Executor) trackOperation(ctx context.Context, cacheKey uint64, operation *graphql.Request) {
+	tracked, ok := e.trackedOperation(cacheKey)
+	if !ok {
+		query, err := astprinter.PrintString(operation.Document())
+		if err != nil {
+			return
+		}
+
+		tracked = &trackedOperation{
+			operation: Operation{
+				Hash:          cacheKey,
+				Query:         query,
+				OperationName: operation.OperationName,
+				Contract:      e.contract,
+			},
+		}
+		e.operations.Add(cacheKey, tracked)
+	}
+
+	hits := tracked.hits.Add(1)
+	if e.operationStore == nil || hits&(hits-1) != 0 {
+		return
+	}
+
+	saved := tracked.operation
+	saved.Hits = hits
+	go func() {
+		_ = e.operationStore.Save(context.WithoutCancel(ctx), saved)
+	}()
+}
+
+func (e *Executor) trackedOperation(cacheKey uint64) (*trackedOperation, bool) {
+	cached, ok := e.operations.Get(cacheKey)
+	if !ok {
+		return nil, false
+	}
+	tracked, ok := cached.(*trackedOperation)
+	return tracked, ok
+}
+
+// MostUsedOperations returns up to limit tracked operations ordered by the number of plan cache hits.
+func (e *Executor) MostUsedOperations(limit int) []Operation {
+	tracked := make([]*trackedOperation, 0, e.operations.Len())
+	for _, key := range e.operations.Keys() {
+		if cached, ok := e.operations.Peek(key); ok {
+			if op, ok := cached.(*trackedOperation); ok {
+				tracked = append(tracked, op)
+			}
+		}
+	}
+
+	sort.SliceStable(tracked, func(i, j int) bool {
+		return tracked[i].hits.Load() > tracked[j].hits.Load()
+	})
+
+	if limit > 0 && len(tracked) > limit {
+		tracked = tracked[:limit]
+	}
+
+	operations := make([]Operation, 0, len(tracked))
+	for _, op := range tracked {
+		operation := op.operation
+		operation.Hits = op.hits.Load()
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// Warmup plans the given operations against the executor schema and stores them in the plan cache.
+// The operations keep their hits, so that the next warmup replans the most used ones again,
+// but they are neither counted as hits nor saved to the store once more.
+// It returns the number of planned operations together with the errors of the ones that failed.
+func (e *Executor) Warmup(ctx context.Context, operations []Operation) (int, error) {
+	var (
+		planned int
+		errs    []error
+	)
+
+	for _, op := range operations {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		request := &graphql.Request{
+			Query:         op.Query,
+			OperationName: op.OperationName,
+		}
+
+		if err := e.normalizeDocument(request); err != nil {
+			errs = append(errs, fmt.Errorf("normalize operation %q: %w", op.OperationName, err))
+			continue
+		}
+
+		cacheKey, err := planCacheKey(request)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hash operation %q: %w", op.OperationName, err))
+			continue
+		}
+
+		if !e.executionPlanCache.Contains(cacheKey) {
+			execContext := newInternalExecutionContext()
+			execContext.prepare(ctx, nil, request.InternalRequest())
+
+			var report operationreport.Report
+			p := e.planOperation(execContext, request, &report)
+			if report.HasErrors() || p == nil {
+				errs = append(errs, fmt.Errorf("plan operation %q: %w", op.OperationName, report))
+				continue
+			}
+			e.executionPlanCache.Add(cacheKey, p)
+		}
+
+		if _, ok := e.trackedOperation(cacheKey); !ok {
+			tracked := &trackedOperation{
+				operation: Operation{
+					Hash:          cacheKey,
+					Query:         op.Query,
+					OperationName: op.OperationName,
+					Contract:      e.contract,
+				},
+			}
+			tracked.hits.Store(op.Hits)
+			e.operations.Add(cacheKey, tracked)
+		}
+
+		planned++
+	}
+
+	return planned, errors.Join(errs...)
+}
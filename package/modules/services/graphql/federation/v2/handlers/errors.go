@@ -0,0 +1,68 @@
+package fhandlers
+
+import (
+	"fmt"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const DefaultMaskedErrorMessage = "Internal server error"
+
+// ErrorMasker replaces the message of internal errors so that subgraph internals are not exposed to clients.
+// Errors without a code, or with one of the internal codes, are considered internal.
+type ErrorMasker struct {
+	message       string
+	internalCodes map[string]struct{}
+}
+
+func NewErrorMasker(message string, internalCodes []string) *ErrorMasker {
+	if message == "" {
+		message = DefaultMaskedErrorMessage
+	}
+
+	codes := make(map[string]struct{}, len(internalCodes))
+	for _, code := range internalCodes {
+		codes[code] = struct{}{}
+	}
+
+	return &ErrorMasker{
+		message:       message,
+		internalCodes: codes,
+	}
+}
+
+// Mask rewrites the root errors of a GraphQL response, including the subgraph errors nested by the wrapped mode.
+func (m *ErrorMasker) Mask(body []byte) []byte {
+	return m.maskErrors(body, "errors")
+}
+
+func (m *ErrorMasker) maskErrors(body []byte, path string) []byte {
+	errs := gjson.GetBytes(body, path)
+	if !errs.IsArray() {
+		return body
+	}
+
+	for i, gqlErr := range errs.Array() {
+		errPath := fmt.Sprintf("%s.%d", path, i)
+
+		if m.isInternal(gqlErr.Get("extensions.code").String()) {
+			if masked, err := sjson.SetBytes(body, errPath+".message", m.message); err == nil {
+				body = masked
+			}
+		}
+
+		body = m.maskErrors(body, errPath+".extensions.errors")
+	}
+
+	return body
+}
+
+func (m *ErrorMasker) isInternal(code string) bool {
+	if code == "" {
+		return true
+	}
+
+	_, ok := m.internalCodes[code]
+	return ok
+}
@@ -7,12 +7,14 @@ import (
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
+	"go.uber.org/zap"
 
 	"github.com/wundergraph/graphql-go-tools/execution/graphql"
 
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/executor"
 	fwebsocket "github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/handlers/websocket"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
 )
 
 const (
@@ -20,17 +22,32 @@ const (
 	httpContentTypeApplicationJson string = "application/json"
 )
 
+type FederationHandlerOptions struct {
+	Logger   *logging.Logger
+	Executor *executor.Executor
+
+	// ErrorMasker is optional, when set internal error messages are masked before responding
+	ErrorMasker *ErrorMasker
+
+	// Plugins receive the client response before it is written
+	Plugins []plugin.Plugin
+}
+
 type FederationHandler struct {
-	log      *logging.Logger
-	executor *executor.Executor
+	log         *logging.Logger
+	executor    *executor.Executor
+	errorMasker *ErrorMasker
+	plugins     plugin.Chain
 
 	wsHandler *fwebsocket.WebSocketFederationHandler
 }
 
-func NewFederationHandler(log *logging.Logger, executor *executor.Executor) *FederationHandler {
+func NewFederationHandler(opts FederationHandlerOptions) *FederationHandler {
 	return &FederationHandler{
-		log:      log,
-		executor: executor,
+		log:         opts.Logger,
+		executor:    opts.Executor,
+		errorMasker: opts.ErrorMasker,
+		plugins:     opts.Plugins,
 	}
 }
 
@@ -42,6 +59,7 @@ func (h *FederationHandler) ServeWS(c *websocket.Conn) {
 	h.wsHandler = fwebsocket.NewWebSocketFederationHandler(context.Background(), fwebsocket.WebSocketFederationHandlerOptions{
 		Logger:       h.log,
 		Executor:     h.executor,
+		OnResponse:   h.onResponse,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	})
@@ -66,8 +84,32 @@ func (h *FederationHandler) handleRequest(w http.ResponseWriter, r *http.Request
 	}
 
 	w.Header().Add(httpHeaderContentType, httpContentTypeApplicationJson)
-	w.WriteHeader(http.StatusOK)
-	if _, err = w.Write(buf.Bytes()); err != nil {
+
+	response := &plugin.Response{
+		StatusCode: http.StatusOK,
+		Header:     w.Header(),
+		Body:       buf.Bytes(),
+	}
+	if err = h.onResponse(r.Context(), response); err != nil {
+		h.log.Error("failed to run response plugins", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+
+	w.WriteHeader(response.StatusCode)
+	if _, err = w.Write(response.Body); err != nil {
+		return
+	}
+}
+
+// onResponse runs the response plugins then masks the internal errors, for every transport
+func (h *FederationHandler) onResponse(ctx context.Context, response *plugin.Response) error {
+	if err := h.plugins.OnResponse(ctx, response); err != nil {
+		return err
+	}
+
+	if h.errorMasker != nil {
+		response.Body = h.errorMasker.Mask(response.Body)
+	}
+	return nil
 }
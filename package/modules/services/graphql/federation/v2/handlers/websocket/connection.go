@@ -20,6 +20,7 @@ import (
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/executor"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/handlers/wsprotocol"
+	fpubsub "github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/pubsub"
 )
 
 type SubscriptionRegistration struct {
@@ -31,6 +32,8 @@ type WebSocketConnectionHandlerOptions struct {
 	Logger   *logging.Logger
 	Executor *executor.Executor
 
+	OnResponse ResponseFunc
+
 	Request        *http.Request
 	ResponseWriter http.ResponseWriter
 
@@ -46,6 +49,8 @@ type WebSocketConnectionHandler struct {
 	logger   *logging.Logger
 	executor *executor.Executor
 
+	onResponse ResponseFunc
+
 	conn     *wsConnectionWrapper
 	protocol wsprotocol.Protocol
 
@@ -64,6 +69,8 @@ func NewWebSocketConnectionHandler(ctx context.Context, opts WebSocketConnection
 		logger:   opts.Logger,
 		executor: opts.Executor,
 
+		onResponse: opts.OnResponse,
+
 		conn:     opts.Connection,
 		protocol: opts.Protocol,
 
@@ -98,7 +105,7 @@ func (h *WebSocketConnectionHandler) writeErrorMessage(operationID string, err e
 }
 
 func (h *WebSocketConnectionHandler) executeSubscription(registration *SubscriptionRegistration) {
-	rw := newWebsocketResponseWriter(registration.msg.ID, h.protocol, true, h.logger)
+	rw := newWebsocketResponseWriter(h.ctx, registration.msg.ID, h.protocol, h.onResponse, h.logger)
 
 	gqlRequest, err := h.UnmarshalOperationFromBody(registration.msg.Payload)
 	if err != nil {
@@ -208,6 +215,16 @@ func (h *WebSocketConnectionHandler) Initialize() (err error) {
 		return err
 	}
 
+	// the stream subscriptions of a resuming client replay the events missed while it was disconnected
+	replay, ok, err := fpubsub.ReplayFromInitPayload(h.initialPayload)
+	if err != nil {
+		_ = h.requestError(err)
+		return err
+	}
+	if ok {
+		h.ctx = fpubsub.WithReplay(h.ctx, replay)
+	}
+
 	return nil
 }
 
@@ -15,12 +15,19 @@ import (
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/executor"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/handlers/wsprotocol"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
 )
 
+// ResponseFunc mutates a response before it is written to the client, e.g. to mask its errors
+type ResponseFunc func(ctx context.Context, response *plugin.Response) error
+
 type WebSocketFederationHandlerOptions struct {
 	Logger   *logging.Logger
 	Executor *executor.Executor
 
+	// OnResponse is optional, it runs on every result sent to the client
+	OnResponse ResponseFunc
+
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 
@@ -34,6 +41,8 @@ type WebSocketFederationHandler struct {
 	logger   *logging.Logger
 	executor *executor.Executor
 
+	onResponse ResponseFunc
+
 	netPoll       netpoll.Poller
 	connections   map[int]*WebSocketConnectionHandler
 	connectionsMu sync.RWMutex
@@ -48,6 +57,8 @@ func NewWebSocketFederationHandler(ctx context.Context, opts WebSocketFederation
 		logger:   opts.Logger,
 		executor: opts.Executor,
 
+		onResponse: opts.OnResponse,
+
 		readTimeout:  opts.ReadTimeout,
 		writeTimeout: opts.WriteTimeout,
 	}
@@ -78,6 +89,8 @@ func (h *WebSocketFederationHandler) HandleWSUpgradeRequest(c *websocket.Conn) {
 		Logger:   h.logger,
 		Executor: h.executor,
 
+		OnResponse: h.onResponse,
+
 		Protocol:   protocol,
 		Connection: conn,
 	})
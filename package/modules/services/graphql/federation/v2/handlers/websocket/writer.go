@@ -2,6 +2,7 @@ package fwebsocket
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -12,14 +13,17 @@ import (
 
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/handlers/wsprotocol"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
 )
 
 type websocketResponseWriter struct {
+	ctx          context.Context
 	id           string
 	protocol     wsprotocol.Protocol
 	header       http.Header
 	buf          bytes.Buffer
 	writtenBytes int
+	onResponse   ResponseFunc
 	logger       *zap.Logger
 }
 
@@ -27,12 +31,14 @@ var _ http.ResponseWriter = (*websocketResponseWriter)(nil)
 
 var _ resolve.SubscriptionResponseWriter = (*websocketResponseWriter)(nil)
 
-func newWebsocketResponseWriter(id string, protocol wsprotocol.Protocol, propagateErrors bool, logger *logging.Logger) *websocketResponseWriter {
+func newWebsocketResponseWriter(ctx context.Context, id string, protocol wsprotocol.Protocol, onResponse ResponseFunc, logger *logging.Logger) *websocketResponseWriter {
 	return &websocketResponseWriter{
-		id:       id,
-		protocol: protocol,
-		header:   make(http.Header),
-		logger:   logger.With(zap.String("subscription_id", id)),
+		ctx:        ctx,
+		id:         id,
+		protocol:   protocol,
+		header:     make(http.Header),
+		onResponse: onResponse,
+		logger:     logger.With(zap.String("subscription_id", id)),
 	}
 }
 
@@ -69,6 +75,21 @@ func (rw *websocketResponseWriter) Flush() error {
 		payload := rw.buf.Bytes()
 		var extensions []byte
 		var err error
+
+		if rw.onResponse != nil {
+			response := &plugin.Response{
+				StatusCode: http.StatusOK,
+				Header:     rw.header,
+				Body:       payload,
+			}
+			if err = rw.onResponse(rw.ctx, response); err != nil {
+				rw.logger.Warn("Running response plugins", zap.Error(err))
+				rw.buf.Reset()
+				return err
+			}
+			payload = response.Body
+		}
+
 		if len(rw.header) > 0 {
 			extensions, err = json.Marshal(map[string]any{
 				"response_headers": rw.header,
@@ -13,6 +13,7 @@ import (
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/graphql_datasource"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
 
+	pkgconfig "github.com/gianglt2198/federation-go/package/config"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/types"
 	fpubsub "github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/pubsub"
@@ -26,7 +27,7 @@ type FactoryResolver interface {
 type RouterEngineConfiguration struct {
 	Execution                config.EngineExecutionConfiguration
 	Headers                  *config.HeaderRules
-	Events                   config.EventsConfiguration
+	Events                   pkgconfig.EventsConfig
 	SubgraphErrorPropagation config.SubgraphErrorPropagationConfiguration
 }
 
@@ -63,10 +64,15 @@ func (l *Loader) Load(engineConfig *nodev1.EngineConfiguration, subgraphs []*nod
 			}
 			args = append(args, arg)
 		}
+		filterCondition, err := subscriptionFilterCondition(configuration.SubscriptionFilterCondition)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid subscription filter of %s.%s: %w", configuration.TypeName, configuration.FieldName, err)
+		}
 		fieldConfig := plan.FieldConfiguration{
-			TypeName:  configuration.TypeName,
-			FieldName: configuration.FieldName,
-			Arguments: args,
+			TypeName:                    configuration.TypeName,
+			FieldName:                   configuration.FieldName,
+			Arguments:                   args,
+			SubscriptionFilterCondition: filterCondition,
 		}
 		outConfig.Fields = append(outConfig.Fields, fieldConfig)
 	}
@@ -0,0 +1,106 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/buger/jsonparser"
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/argument_templates"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+)
+
+// subscriptionFilterCondition maps the @openfed__subscriptionFilter condition of a field, composed as
+// nested IN, AND, OR and NOT conditions. The engine evaluates it against each event payload,
+// before the entity fields are resolved, and skips the events which do not match.
+// An invalid condition is an error: dropping it would forward every event to the subscribers.
+func subscriptionFilterCondition(in *nodev1.SubscriptionFilterCondition) (*plan.SubscriptionFilterCondition, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	out := &plan.SubscriptionFilterCondition{}
+	switch {
+	case in.And != nil:
+		conditions, err := subscriptionFilterConditions(in.And)
+		if err != nil {
+			return nil, fmt.Errorf("AND: %w", err)
+		}
+		out.And = conditions
+	case in.Or != nil:
+		conditions, err := subscriptionFilterConditions(in.Or)
+		if err != nil {
+			return nil, fmt.Errorf("OR: %w", err)
+		}
+		out.Or = conditions
+	case in.Not != nil:
+		condition, err := subscriptionFilterCondition(in.Not)
+		if err != nil {
+			return nil, fmt.Errorf("NOT: %w", err)
+		}
+		out.Not = condition
+	case in.In != nil:
+		values, err := subscriptionFilterValues(in.In.Json)
+		if err != nil {
+			return nil, fmt.Errorf("IN %s: %w", strings.Join(in.In.FieldPath, "."), err)
+		}
+		out.In = &plan.SubscriptionFieldCondition{
+			FieldPath: in.In.FieldPath,
+			Values:    values,
+		}
+	default:
+		return nil, errors.New("empty condition")
+	}
+
+	return out, nil
+}
+
+func subscriptionFilterConditions(in []*nodev1.SubscriptionFilterCondition) ([]plan.SubscriptionFilterCondition, error) {
+	if len(in) == 0 {
+		return nil, errors.New("no conditions")
+	}
+
+	out := make([]plan.SubscriptionFilterCondition, 0, len(in))
+	for i, condition := range in {
+		mapped, err := subscriptionFilterCondition(condition)
+		if err != nil {
+			return nil, fmt.Errorf("condition %d: %w", i, err)
+		}
+		if mapped == nil {
+			return nil, fmt.Errorf("condition %d: empty condition", i)
+		}
+		out = append(out, *mapped)
+	}
+	return out, nil
+}
+
+// subscriptionFilterValues keeps the JSON representation of the IN values. Strings are quoted again
+// so that their type survives the transport to the engine, argument templates are kept as they are
+// to be exploded with the values of the operation. The strings are quoted with their escapes, like the
+// router of cosmo does: the engine quotes the still escaped string of the event field the same way
+// before comparing it, so ["a\"b"] matches the field "a\"b".
+func subscriptionFilterValues(data string) ([]string, error) {
+	var values []string
+	var mErr error
+
+	_, err := jsonparser.ArrayEach([]byte(data), func(value []byte, dataType jsonparser.ValueType, _ int, _ error) {
+		if dataType != jsonparser.String || argument_templates.ContainsArgumentTemplateString(value) {
+			values = append(values, string(value))
+			return
+		}
+
+		quoted, err := json.Marshal(string(value))
+		if err != nil {
+			mErr = err
+			return
+		}
+		values = append(values, string(quoted))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return values, mErr
+}
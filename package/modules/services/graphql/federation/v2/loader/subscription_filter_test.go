@@ -0,0 +1,117 @@
+package loader
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func in(json string, path ...string) *nodev1.SubscriptionFilterCondition {
+	return &nodev1.SubscriptionFilterCondition{In: &nodev1.SubscriptionFieldCondition{FieldPath: path, Json: json}}
+}
+
+func TestSubscriptionFilterCondition(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      *nodev1.SubscriptionFilterCondition
+		want    *plan.SubscriptionFilterCondition
+		wantErr bool
+	}{
+		{name: "none"},
+		{
+			name: "in values keep their JSON type, templates are kept as they are",
+			in:   in(`["u1", 2, true, "{{ args.id }}"]`, "user", "id"),
+			want: &plan.SubscriptionFilterCondition{In: &plan.SubscriptionFieldCondition{
+				FieldPath: []string{"user", "id"},
+				Values:    []string{`"u1"`, "2", "true", "{{ args.id }}"},
+			}},
+		},
+		{
+			name: "escaped strings keep their escapes",
+			in:   in(`["a\"b", "\u00e9"]`, "id"),
+			want: &plan.SubscriptionFilterCondition{In: &plan.SubscriptionFieldCondition{
+				FieldPath: []string{"id"},
+				Values:    []string{`"a\\\"b"`, `"\\u00e9"`},
+			}},
+		},
+		{
+			name: "nested",
+			in: &nodev1.SubscriptionFilterCondition{And: []*nodev1.SubscriptionFilterCondition{
+				in(`["u1"]`, "id"),
+				{Not: &nodev1.SubscriptionFilterCondition{Or: []*nodev1.SubscriptionFilterCondition{in(`["deleted"]`, "status")}}},
+			}},
+			want: &plan.SubscriptionFilterCondition{And: []plan.SubscriptionFilterCondition{
+				{In: &plan.SubscriptionFieldCondition{FieldPath: []string{"id"}, Values: []string{`"u1"`}}},
+				{Not: &plan.SubscriptionFilterCondition{Or: []plan.SubscriptionFilterCondition{
+					{In: &plan.SubscriptionFieldCondition{FieldPath: []string{"status"}, Values: []string{`"deleted"`}}},
+				}}},
+			}},
+		},
+		{name: "empty condition", in: &nodev1.SubscriptionFilterCondition{}, wantErr: true},
+		{name: "and without conditions", in: &nodev1.SubscriptionFilterCondition{And: []*nodev1.SubscriptionFilterCondition{}}, wantErr: true},
+		{
+			name:    "or with an empty condition",
+			in:      &nodev1.SubscriptionFilterCondition{Or: []*nodev1.SubscriptionFilterCondition{in(`["u1"]`, "id"), nil}},
+			wantErr: true,
+		},
+		{name: "not of an empty condition", in: &nodev1.SubscriptionFilterCondition{Not: &nodev1.SubscriptionFilterCondition{}}, wantErr: true},
+		{name: "in values not an array", in: in(`{"id":"u1"}`, "id"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := subscriptionFilterCondition(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("condition = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// the engine compares the values with the event fields, the escaped strings must match as they are sent
+func TestSubscriptionFilterValuesMatchEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		values string
+		event  string
+		match  bool
+	}{
+		{name: "string", values: `["u1"]`, event: `{"id":"u1"}`, match: true},
+		{name: "escaped quote", values: `["a\"b"]`, event: `{"id":"a\"b"}`, match: true},
+		{name: "escaped backslash", values: `["a\\b"]`, event: `{"id":"a\\b"}`, match: true},
+		{name: "unescaped value", values: `["a\"b"]`, event: `{"id":"a\\\"b"}`, match: false},
+		{name: "number", values: `[2]`, event: `{"id":2}`, match: true},
+		{name: "number as string", values: `["2"]`, event: `{"id":2}`, match: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := subscriptionFilterValues(tt.values)
+			if err != nil {
+				t.Fatalf("values: %v", err)
+			}
+
+			filter := &resolve.SubscriptionFieldFilter{FieldPath: []string{"id"}}
+			for _, value := range values {
+				filter.Values = append(filter.Values, resolve.InputTemplate{Segments: []resolve.TemplateSegment{
+					{SegmentType: resolve.StaticSegmentType, Data: []byte(value)},
+				}})
+			}
+
+			skip, err := filter.SkipEvent(&resolve.Context{}, []byte(tt.event), &bytes.Buffer{})
+			if err != nil {
+				t.Fatalf("skip event: %v", err)
+			}
+			if skip == tt.match {
+				t.Fatalf("event %s matched = %v, want %v with the values %q", tt.event, !skip, tt.match, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"net/http"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/wundergraph/cosmo/composition-go"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/types"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/executor"
+	fhandlers "github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/handlers"
+)
+
+// contracts maps the configured variants to executor contracts
+func (f *federationManager) contracts() []executor.Contract {
+	variants := f.federationConfig.Contracts.Variants
+
+	contracts := make([]executor.Contract, 0, len(variants))
+	for _, variant := range variants {
+		contracts = append(contracts, executor.Contract{
+			Name:        variant.Name,
+			ExcludeTags: variant.ExcludeTags,
+		})
+	}
+	return contracts
+}
+
+// collectTags reads the @tag directives of the subgraphs, which are stripped by the composition
+func (f *federationManager) collectTags(subgraphs []*composition.Subgraph) (executor.Tags, error) {
+	if len(f.federationConfig.Contracts.Variants) == 0 {
+		return nil, nil
+	}
+
+	sdls := make([]string, 0, len(subgraphs))
+	for _, subgraph := range subgraphs {
+		sdls = append(sdls, subgraph.Schema)
+	}
+	return executor.CollectTags(sdls...)
+}
+
+// contractHandlers creates a federation handler for each contract of the executor
+func (f *federationManager) contractHandlers(exec *executor.Executor) map[string]types.FederationHandler {
+	handlers := make(map[string]types.FederationHandler, len(f.federationConfig.Contracts.Variants))
+	for _, variant := range f.federationConfig.Contracts.Variants {
+		contractExec, ok := exec.Contract(variant.Name)
+		if !ok {
+			continue
+		}
+
+		handlers[variant.Name] = fhandlers.NewFederationHandler(fhandlers.FederationHandlerOptions{
+			Logger:      f.logger,
+			Executor:    contractExec,
+			ErrorMasker: f.errorMasker(),
+			Plugins:     f.plugins,
+		})
+	}
+	return handlers
+}
+
+func (f *federationManager) registerContractRoutes(app *fiber.App) {
+	for _, variant := range f.federationConfig.Contracts.Variants {
+		if variant.Route == "" {
+			continue
+		}
+
+		name := variant.Name
+		app.Get(variant.Route+"/ws", websocket.New(func(c *websocket.Conn) {
+			f.serveContractWS(name, c)
+		}, websocket.Config{
+			Subprotocols: []string{"graphql-transport-ws", "graphql-ws"},
+		}))
+		app.All(variant.Route, adaptor.HTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f.serveContract(name, w, r)
+		}))
+
+		f.logger.Info("Serving contract", zap.String("contract", name), zap.String("route", variant.Route))
+	}
+}
+
+// contractHandler returns the handler of the contract, and whether the contract is declared:
+// a declared contract has no handler until the first schema is composed
+func (f *federationManager) contractHandler(name string) (types.FederationHandler, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if handler, ok := f.contractsHandlers[name]; ok {
+		return handler, true
+	}
+
+	for _, variant := range f.federationConfig.Contracts.Variants {
+		if variant.Name == name {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+func (f *federationManager) serveContract(name string, w http.ResponseWriter, r *http.Request) {
+	handler, declared := f.contractHandler(name)
+	switch {
+	case !declared:
+		http.Error(w, "Unknown contract "+name, http.StatusNotFound)
+	case handler == nil:
+		http.Error(w, "Contract "+name+" not ready", http.StatusServiceUnavailable)
+	default:
+		handler.ServeHTTP(w, r)
+	}
+}
+
+func (f *federationManager) serveContractWS(name string, c *websocket.Conn) {
+	handler, declared := f.contractHandler(name)
+	switch {
+	case !declared:
+		_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Unknown contract "+name))
+	case handler == nil:
+		_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "Contract "+name+" not ready"))
+	default:
+		handler.ServeWS(c)
+	}
+}
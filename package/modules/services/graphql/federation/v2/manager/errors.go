@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"slices"
+
+	routerCfg "github.com/wundergraph/cosmo/router/pkg/config"
+
+	fhandlers "github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/handlers"
+	graphqlUtils "github.com/gianglt2198/federation-go/package/modules/services/graphql/utils"
+)
+
+const productionEnvironment = "production"
+
+var (
+	defaultAllowedExtensionFields = []string{"code", "request_id"}
+	defaultInternalErrorCodes     = []string{
+		graphqlUtils.InternalServerErrorCode,
+		graphqlUtils.UnknownErrorCode,
+	}
+)
+
+// subgraphErrorPropagation translates the errors policy of the federation config into the router configuration.
+func (f *federationManager) subgraphErrorPropagation() routerCfg.SubgraphErrorPropagationConfiguration {
+	cfg := f.federationConfig.Errors
+
+	// the mode is validated when the manager is created
+	mode := routerCfg.SubgraphErrorPropagationModePassthrough
+	if cfg.Mode != "" {
+		mode = routerCfg.SubgraphErrorPropagationMode(cfg.Mode)
+	}
+
+	allowedExtensionFields := cfg.AllowedExtensionFields
+	if len(allowedExtensionFields) == 0 {
+		allowedExtensionFields = defaultAllowedExtensionFields
+	}
+
+	return routerCfg.SubgraphErrorPropagationConfiguration{
+		Enabled:                 true,
+		Mode:                    mode,
+		RewritePaths:            true,
+		PropagateStatusCodes:    cfg.PropagateStatusCodes,
+		OmitLocations:           cfg.OmitLocations,
+		AttachServiceName:       cfg.AttachServiceName,
+		DefaultExtensionCode:    cfg.DefaultExtensionCode,
+		AllowAllExtensionFields: cfg.AllowAllExtensionFields,
+		AllowedExtensionFields:  allowedExtensionFields,
+	}
+}
+
+// errorMasker returns the masker for internal error messages, or nil when masking is disabled.
+func (f *federationManager) errorMasker() *fhandlers.ErrorMasker {
+	cfg := f.federationConfig.Errors
+	if !cfg.MaskInternalErrors && f.appConfig.Environment != productionEnvironment {
+		return nil
+	}
+
+	internalCodes := cfg.InternalErrorCodes
+	if len(internalCodes) == 0 {
+		internalCodes = defaultInternalErrorCodes
+	}
+	if cfg.DefaultExtensionCode != "" {
+		internalCodes = append(slices.Clone(internalCodes), cfg.DefaultExtensionCode)
+	}
+
+	return fhandlers.NewErrorMasker(cfg.MaskedMessage, internalCodes)
+}
@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/types"
+)
+
+// eventsConfig returns the configured EDFS providers. Without any, the default provider
+// uses the NATS connection of the service so that subjects match the services base path.
+// The providers publish as the gateway, like the psnats clients publish as their service.
+func (f *federationManager) eventsConfig() config.EventsConfig {
+	configured := f.federationConfig.Events.Providers
+	if len(configured) == 0 && f.natsConfig.Enabled {
+		configured = []config.EventProviderConfig{
+			{
+				ID:             config.DefaultEventProviderID,
+				Type:           config.EventProviderTypeNats,
+				URL:            f.natsConfig.URLs(),
+				BasePath:       f.natsConfig.BasePath,
+				ClientName:     f.natsConfig.Name,
+				Authentication: f.natsConfig.Authentication,
+				TLS:            f.natsConfig.TLS,
+			},
+		}
+	}
+
+	providers := make([]config.EventProviderConfig, 0, len(configured))
+	for _, provider := range configured {
+		if provider.ClientName == "" {
+			provider.ClientName = f.appConfig.Name
+		}
+		providers = append(providers, provider)
+	}
+	return config.EventsConfig{Providers: providers}
+}
+
+// instanceData identifies the gateway replica to the event providers
+func (f *federationManager) instanceData() types.InstanceData {
+	hostName, err := os.Hostname()
+	if err != nil || hostName == "" {
+		hostName = f.appConfig.Name
+	}
+
+	return types.InstanceData{
+		HostName:      hostName,
+		ListenAddress: strconv.Itoa(f.httpConfig.Port),
+	}
+}
@@ -2,6 +2,7 @@ package manager
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"sync"
 
@@ -21,6 +22,7 @@ import (
 	"github.com/wundergraph/cosmo/router/pkg/statistics"
 
 	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/cache"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/infras/pubsub"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/common"
@@ -28,6 +30,8 @@ import (
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/executor"
 	fhandlers "github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/handlers"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/loader"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
+	fpubsub "github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/pubsub"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/registry"
 	httpServer "github.com/gianglt2198/federation-go/package/modules/services/http/server"
 )
@@ -36,21 +40,32 @@ import (
 type federationManager struct {
 	appConfig        config.AppConfig
 	federationConfig config.FederationConfig
+	natsConfig       config.NATSConfig
+	httpConfig       config.HTTPConfig
 
 	logger *logging.Logger
 	mu     sync.RWMutex
 
-	handler    types.FederationHandler
+	handler  types.FederationHandler
+	executor *executor.Executor
+
+	contractsHandlers map[string]types.FederationHandler
+
 	httpServer httpServer.HTTPServer
 	registry   *registry.SchemaRegistry
 	broker     pubsub.Broker
 
+	operationStore executor.OperationStore
+	plugins        []plugin.Plugin
+
 	schemas []*composition.Subgraph
 
 	pubsubProviders []datasource.Provider
 
 	readyCh   chan struct{}
 	readyOnce *sync.Once
+	// startErr is set when the first schema build fails because of the configuration
+	startErr error
 }
 
 // FederationManager defines the interface for federation management
@@ -68,24 +83,52 @@ type FederationManagerParams struct {
 	Logger           *logging.Logger
 	AppConfig        config.AppConfig
 	FederationConfig config.FederationConfig
+	RedisConfig      config.RedisConfig
+	NATSConfig       config.NATSConfig
+	HTTPConfig       config.HTTPConfig
 	HTTPServer       httpServer.HTTPServer
 	SchemaRegistry   *registry.SchemaRegistry
 	Broker           pubsub.Broker
+	Cache            cache.Cache     `optional:"true"`
+	Plugins          []plugin.Plugin `group:"federation_plugins"`
 }
 
 // New creates a new federation manager
-func New(params FederationManagerParams) FederationManager {
+func New(params FederationManagerParams) (FederationManager, error) {
 	f := &federationManager{
 		logger:           params.Logger,
 		httpServer:       params.HTTPServer,
 		appConfig:        params.AppConfig,
 		federationConfig: params.FederationConfig,
+		natsConfig:       params.NATSConfig,
+		httpConfig:       params.HTTPConfig,
 		registry:         params.SchemaRegistry,
 		broker:           params.Broker,
+		plugins:          params.Plugins,
 		readyCh:          make(chan struct{}),
 		readyOnce:        &sync.Once{},
 	}
 
+	if err := f.eventsConfig().Validate(); err != nil {
+		return nil, fmt.Errorf("invalid federation events configuration: %w", err)
+	}
+
+	if err := f.federationConfig.Contracts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid federation contracts configuration: %w", err)
+	}
+
+	if err := f.federationConfig.Errors.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid federation errors configuration: %w", err)
+	}
+
+	if f.federationConfig.PlanCache.Persist {
+		if params.RedisConfig.Enabled && params.Cache != nil {
+			f.operationStore = newCacheOperationStore(params.Cache, f.appConfig.Name, f.federationConfig.PlanCache.PersistTTL)
+		} else {
+			f.logger.Warn("Plan cache persistence requires redis, operations will not be persisted")
+		}
+	}
+
 	f.registry.Register(f)
 	go f.registry.Start(context.Background())
 
@@ -108,6 +151,7 @@ func New(params FederationManagerParams) FederationManager {
 		}))
 
 		app.All("/graphql", adaptor.HTTPHandler(f))
+		f.registerContractRoutes(app)
 		app.Get(
 			"/playground",
 			adaptor.HTTPHandlerFunc(playground.ApolloSandboxHandler(
@@ -120,7 +164,7 @@ func New(params FederationManagerParams) FederationManager {
 		)
 	}
 
-	return f
+	return f, nil
 }
 
 func (f *federationManager) RegisterSchema(url, name, sdl string) {
@@ -130,6 +174,11 @@ func (f *federationManager) RegisterSchema(url, name, sdl string) {
 }
 
 func (f *federationManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if contract := f.federationConfig.Contracts.Default; contract != "" {
+		f.serveContract(contract, w, r)
+		return
+	}
+
 	if f.handler == nil {
 		http.Error(w, "Federation gateway not ready", http.StatusServiceUnavailable)
 		return
@@ -139,8 +188,13 @@ func (f *federationManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (f *federationManager) ServeWS(c *websocket.Conn) {
+	if contract := f.federationConfig.Contracts.Default; contract != "" {
+		f.serveContractWS(contract, c)
+		return
+	}
+
 	if f.handler == nil {
-		_ = c.WriteMessage(websocket.CloseMessage, []byte("Federation gateway not ready"))
+		_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "Federation gateway not ready"))
 		return
 	}
 	f.handler.ServeWS(c)
@@ -155,7 +209,7 @@ func (f *federationManager) Start() error {
 
 	<-f.readyCh
 
-	return nil
+	return f.startErr
 }
 
 func (f *federationManager) Stop() error {
@@ -195,24 +249,15 @@ func (f *federationManager) UpdateDataSources(subgraphsConfigs []*composition.Su
 	}
 
 	routerEngineConfig := &loader.RouterEngineConfiguration{
-		Execution: routerCfg.EngineExecutionConfiguration{},
-		Events: routerCfg.EventsConfiguration{
-			Providers: routerCfg.EventProviders{
-				Nats: []routerCfg.NatsEventSource{
-					{
-						ID:  "default",
-						URL: "nats://localhost:4223",
-					},
-				},
-			},
-		},
-		SubgraphErrorPropagation: routerCfg.SubgraphErrorPropagationConfiguration{
-			Enabled:                 true,
-			AllowAllExtensionFields: true,
-			// AllowedErrorExtensionFields: []string{"code", "request_id", "stack_trace"},
-			Mode:         routerCfg.SubgraphErrorPropagationModePassthrough,
-			RewritePaths: true,
-		},
+		Execution:                routerCfg.EngineExecutionConfiguration{},
+		Events:                   f.eventsConfig(),
+		SubgraphErrorPropagation: f.subgraphErrorPropagation(),
+	}
+
+	tags, err := f.collectTags(subgraphsConfigs)
+	if err != nil {
+		f.logger.Error("Failed to collect subgraph tags", zap.Error(err))
+		return
 	}
 
 	engineStats := statistics.NewNoopEngineStats()
@@ -225,10 +270,12 @@ func (f *federationManager) UpdateDataSources(subgraphsConfigs []*composition.Su
 		Broker:             f.broker,
 		Logger:             f.logger,
 		Introspection:      true,
-		InstanceData: types.InstanceData{
-			HostName:      "localhost",
-			ListenAddress: "4223",
-		},
+		PlanCacheSize:      f.federationConfig.PlanCache.Size,
+		OperationStore:     f.operationStore,
+		Plugins:            f.plugins,
+		Contracts:          f.contracts(),
+		Tags:               tags,
+		InstanceData:       f.instanceData(),
 	}
 
 	ecb := executor.ExecutorConfigurationBuilder{}
@@ -238,6 +285,9 @@ func (f *federationManager) UpdateDataSources(subgraphsConfigs []*composition.Su
 	exec, pubsubProviders, err := ecb.Build(ctx, ecbParams)
 	if err != nil {
 		f.logger.Error("Failed to build executor configuration", zap.Error(err))
+		if fpubsub.IsConfigurationError(err) {
+			f.failStartup(err)
+		}
 		return
 	}
 
@@ -247,11 +297,31 @@ func (f *federationManager) UpdateDataSources(subgraphsConfigs []*composition.Su
 		return // Handle startup error
 	}
 
-	handler := fhandlers.NewFederationHandler(f.logger, exec)
+	handler := fhandlers.NewFederationHandler(fhandlers.FederationHandlerOptions{
+		Logger:      f.logger,
+		Executor:    exec,
+		ErrorMasker: f.errorMasker(),
+		Plugins:     f.plugins,
+	})
 
 	f.mu.Lock()
+	previous := f.executor
+	f.executor = exec
 	f.handler = handler
+	f.contractsHandlers = f.contractHandlers(exec)
 	f.mu.Unlock()
 
-	f.readyCh <- struct{}{}
+	go f.warmupExecutor(exec, previous)
+
+	f.readyOnce.Do(func() {
+		close(f.readyCh)
+	})
+}
+
+// failStartup makes Start return the error when the gateway has not served any schema yet
+func (f *federationManager) failStartup(err error) {
+	f.readyOnce.Do(func() {
+		f.startErr = err
+		close(f.readyCh)
+	})
 }
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gianglt2198/federation-go/package/infras/cache"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/executor"
+)
+
+const defaultOperationTTL = 7 * 24 * time.Hour
+
+// cacheOperationStore persists the normalized planned operations in the shared cache so that
+// new gateway replicas can warm their execution plan cache on startup.
+type cacheOperationStore struct {
+	cache  cache.Cache
+	prefix string
+	ttl    time.Duration
+}
+
+var _ executor.OperationStore = (*cacheOperationStore)(nil)
+
+func newCacheOperationStore(c cache.Cache, appName string, ttl time.Duration) *cacheOperationStore {
+	if ttl <= 0 {
+		ttl = defaultOperationTTL
+	}
+
+	return &cacheOperationStore{
+		cache:  c,
+		prefix: fmt.Sprintf("%s:plan_cache:operations:", appName),
+		ttl:    ttl,
+	}
+}
+
+func (s *cacheOperationStore) Save(ctx context.Context, operation executor.Operation) error {
+	data, err := json.Marshal(operation)
+	if err != nil {
+		return err
+	}
+
+	return s.cache.Set(ctx, s.key(operation), data, s.ttl)
+}
+
+// key keeps the operations of each contract apart, the same query hashes alike in every contract
+func (s *cacheOperationStore) key(operation executor.Operation) string {
+	hash := strconv.FormatUint(operation.Hash, 16)
+	if operation.Contract == "" {
+		return s.prefix + hash
+	}
+	return s.prefix + operation.Contract + ":" + hash
+}
+
+// Load returns the limit most used operations, limit <= 0 returns them all
+func (s *cacheOperationStore) Load(ctx context.Context, limit int) ([]executor.Operation, error) {
+	keys, err := cache.ScanKeys(ctx, s.cache, s.prefix+"*")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := s.cache.MGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	operations := make([]executor.Operation, 0, len(values))
+	for _, value := range values {
+		var operation executor.Operation
+		if err := json.Unmarshal(value, &operation); err != nil {
+			continue
+		}
+		operations = append(operations, operation)
+	}
+
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].Hits > operations[j].Hits
+	})
+	if limit > 0 && len(operations) > limit {
+		operations = operations[:limit]
+	}
+
+	return operations, nil
+}
@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/executor"
+)
+
+// warmupExecutor replans the most used operations of the previous executor against the new schema,
+// for the full schema and each contract. On the first build there is no previous executor,
+// so operations are loaded from the operation store instead.
+func (f *federationManager) warmupExecutor(exec, previous *executor.Executor) {
+	ctx := context.Background()
+
+	warmupSize := f.federationConfig.PlanCache.WarmupSize
+	if warmupSize <= 0 {
+		warmupSize = executor.DefaultPlanWarmupSize
+	}
+
+	operations := map[string][]executor.Operation{}
+	if previous != nil {
+		operations[""] = previous.MostUsedOperations(warmupSize)
+		for _, name := range exec.Contracts() {
+			if contract, ok := previous.Contract(name); ok {
+				operations[name] = contract.MostUsedOperations(warmupSize)
+			}
+		}
+	} else if f.operationStore != nil {
+		stored, err := f.operationStore.Load(ctx, 0)
+		if err != nil {
+			f.logger.Error("Failed to load persisted operations", zap.Error(err))
+			return
+		}
+		// the stored operations are the most used first
+		for _, operation := range stored {
+			if len(operations[operation.Contract]) < warmupSize {
+				operations[operation.Contract] = append(operations[operation.Contract], operation)
+			}
+		}
+	}
+
+	f.warmup(ctx, "", exec, operations[""])
+	for _, name := range exec.Contracts() {
+		if contract, ok := exec.Contract(name); ok {
+			f.warmup(ctx, name, contract, operations[name])
+		}
+	}
+}
+
+func (f *federationManager) warmup(ctx context.Context, contract string, exec *executor.Executor, operations []executor.Operation) {
+	if len(operations) == 0 {
+		return
+	}
+
+	planned, err := exec.Warmup(ctx, operations)
+	if err != nil {
+		f.logger.Warn("Some operations could not be replanned", zap.String("contract", contract), zap.Error(err))
+	}
+
+	f.logger.Info("Execution plan cache warmed up",
+		zap.String("contract", contract),
+		zap.Int("planned", planned),
+		zap.Int("operations", len(operations)),
+	)
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/fx"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+)
+
+// GroupName is the fx group the federation manager collects plugins from
+const GroupName = "federation_plugins"
+
+type (
+	// Operation is the client operation after it has been parsed and normalized
+	Operation struct {
+		Name      string
+		Document  *ast.Document
+		Variables []byte
+		Request   *graphql.Request
+	}
+
+	// SubgraphRequest is the request sent to a subgraph, headers and body can be mutated
+	SubgraphRequest struct {
+		Subgraph string
+		Header   http.Header
+		Body     []byte
+	}
+
+	// SubgraphResponse is the response returned by a subgraph, headers and body can be mutated
+	SubgraphResponse struct {
+		Subgraph   string
+		StatusCode int
+		Header     http.Header
+		Body       []byte
+	}
+
+	// Response is the response written to the client, headers and body can be mutated
+	Response struct {
+		StatusCode int
+		Header     http.Header
+		Body       []byte
+	}
+
+	// Plugin extends the federation gateway without forking the manager.
+	// Returning an error from a hook aborts the current request.
+	Plugin interface {
+		Name() string
+		OnOperationReceived(ctx context.Context, operation *Operation) error
+		OnBeforeSubgraphRequest(ctx context.Context, request *SubgraphRequest) error
+		OnAfterSubgraphResponse(ctx context.Context, response *SubgraphResponse) error
+		OnResponse(ctx context.Context, response *Response) error
+	}
+
+	// Chain runs the hooks of several plugins in registration order
+	Chain []Plugin
+)
+
+// Base implements every hook as a no-op, embed it to only override the needed hooks
+type Base struct{}
+
+func (Base) OnOperationReceived(context.Context, *Operation) error { return nil }
+
+func (Base) OnBeforeSubgraphRequest(context.Context, *SubgraphRequest) error { return nil }
+
+func (Base) OnAfterSubgraphResponse(context.Context, *SubgraphResponse) error { return nil }
+
+func (Base) OnResponse(context.Context, *Response) error { return nil }
+
+// AsPlugin annotates a plugin constructor so that it is registered in the federation plugins group
+func AsPlugin(constructor any) any {
+	return fx.Annotate(
+		constructor,
+		fx.As(new(Plugin)),
+		fx.ResultTags(fmt.Sprintf(`group:"%s"`, GroupName)),
+	)
+}
+
+func (c Chain) OnOperationReceived(ctx context.Context, operation *Operation) error {
+	for _, p := range c {
+		if err := p.OnOperationReceived(ctx, operation); err != nil {
+			return fmt.Errorf("plugin %s: %w", p.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c Chain) OnBeforeSubgraphRequest(ctx context.Context, request *SubgraphRequest) error {
+	for _, p := range c {
+		if err := p.OnBeforeSubgraphRequest(ctx, request); err != nil {
+			return fmt.Errorf("plugin %s: %w", p.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c Chain) OnAfterSubgraphResponse(ctx context.Context, response *SubgraphResponse) error {
+	for _, p := range c {
+		if err := p.OnAfterSubgraphResponse(ctx, response); err != nil {
+			return fmt.Errorf("plugin %s: %w", p.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c Chain) OnResponse(ctx context.Context, response *Response) error {
+	for _, p := range c {
+		if err := p.OnResponse(ctx, response); err != nil {
+			return fmt.Errorf("plugin %s: %w", p.Name(), err)
+		}
+	}
+	return nil
+}
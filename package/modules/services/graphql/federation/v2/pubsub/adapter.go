@@ -0,0 +1,292 @@
+package fpubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nats-io/nuid"
+	"github.com/wundergraph/cosmo/router/pkg/pubsub/datasource"
+	cosmonats "github.com/wundergraph/cosmo/router/pkg/pubsub/nats"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
+	"github.com/gianglt2198/federation-go/package/infras/serdes"
+)
+
+const (
+	defaultFlushTimeout = 10 * time.Second
+	// defaultRequestTimeout bounds the requests of the operations without deadline
+	defaultRequestTimeout = 10 * time.Second
+	// defaultConsumerInactiveThreshold lets the server remove the consumers left behind by a crashed gateway
+	defaultConsumerInactiveThreshold = 30 * time.Second
+)
+
+// natsAdapter is the EDFS adapter of a NATS provider.
+// Subscriptions with a stream configuration get their own ephemeral JetStream consumer,
+// which can replay the stream and is deleted when the subscription ends.
+// The payloads are framed like the psnats messages of the services, with the encoding of the provider,
+// and the events are decoded by their content headers, the provider encoding when they have none.
+type natsAdapter struct {
+	ctx          context.Context
+	logger       *zap.Logger
+	url          string
+	opts         []nats.Option
+	flushTimeout time.Duration
+
+	from     string
+	codecs   *serdes.Registry
+	encoding psnats.Encoding
+
+	client  *nats.Conn
+	js      jetstream.JetStream
+	closeWg sync.WaitGroup
+}
+
+var _ cosmonats.Adapter = (*natsAdapter)(nil)
+
+func newNatsAdapter(ctx context.Context, logger *zap.Logger, provider config.EventProviderConfig, opts []nats.Option) *natsAdapter {
+	adapter := &natsAdapter{
+		ctx:          ctx,
+		logger:       logger.With(zap.String("pubsub", "nats")),
+		url:          provider.URL,
+		opts:         opts,
+		flushTimeout: defaultFlushTimeout,
+		from:         provider.ClientName,
+		codecs:       serdes.NewRegistry(serdes.WithDefaultContentType(provider.GetEncoding())),
+		encoding:     psnats.Encoding{ContentType: provider.GetEncoding()},
+	}
+	return adapter
+}
+
+func (a *natsAdapter) Startup(_ context.Context) (err error) {
+	a.client, err = nats.Connect(a.url, a.opts...)
+	if err != nil {
+		return err
+	}
+	a.js, err = jetstream.New(a.client)
+	return err
+}
+
+func (a *natsAdapter) Shutdown(ctx context.Context) error {
+	if a.client == nil || a.client.IsClosed() {
+		return nil
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.flushTimeout)
+		defer cancel()
+	}
+
+	err := errors.Join(a.client.FlushWithContext(ctx), a.client.Drain())
+
+	// wait for the subscriptions, the JetStream ones delete their consumer before returning
+	a.closeWg.Wait()
+
+	if err != nil {
+		return fmt.Errorf("nats pubsub shutdown: %w", err)
+	}
+	return nil
+}
+
+func (a *natsAdapter) Subscribe(ctx context.Context, event cosmonats.SubscriptionEventConfiguration, updater resolve.SubscriptionUpdater) error {
+	return a.subscribe(ctx, event, Replay{}, updater)
+}
+
+func (a *natsAdapter) subscribe(ctx context.Context, event cosmonats.SubscriptionEventConfiguration, replay Replay, updater resolve.SubscriptionUpdater) error {
+	log := a.logger.With(
+		zap.String("provider_id", event.ProviderID),
+		zap.String("method", "subscribe"),
+		zap.Strings("subjects", event.Subjects),
+	)
+
+	if a.client == nil {
+		return datasource.NewError("nats client not initialized", nil)
+	}
+
+	if event.StreamConfiguration != nil {
+		return a.subscribeStream(ctx, log, event, replay, updater)
+	}
+
+	msgChan := make(chan *nats.Msg)
+	subscriptions := make([]*nats.Subscription, 0, len(event.Subjects))
+	for _, subject := range event.Subjects {
+		subscription, err := a.client.ChanSubscribe(subject, msgChan)
+		if err != nil {
+			unsubscribe(log, subscriptions)
+			log.Error("subscribing to NATS subject", zap.Error(err), zap.String("subscription_subject", subject))
+			return datasource.NewError(fmt.Sprintf(`failed to subscribe to NATS subject "%s"`, subject), err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	a.closeWg.Add(1)
+	go func() {
+		defer a.closeWg.Done()
+		defer unsubscribe(log, subscriptions)
+
+		for {
+			select {
+			case msg := <-msgChan:
+				a.update(log, updater, msg)
+			case <-a.ctx.Done():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// subscribeStream creates an ephemeral consumer for the subscription, starting at the replay position
+// or at the new messages, and deletes it when the client disconnects or the gateway stops
+func (a *natsAdapter) subscribeStream(ctx context.Context, log *zap.Logger, event cosmonats.SubscriptionEventConfiguration, replay Replay, updater resolve.SubscriptionUpdater) error {
+	if a.js == nil {
+		return datasource.NewError("nats jetstream not initialized", nil)
+	}
+
+	stream := event.StreamConfiguration
+	consumerConfig := jetstream.ConsumerConfig{
+		Name:              consumerName(stream.Consumer),
+		FilterSubjects:    event.Subjects,
+		AckPolicy:         jetstream.AckNonePolicy,
+		InactiveThreshold: defaultConsumerInactiveThreshold,
+	}
+	if stream.ConsumerInactiveThreshold > 0 {
+		consumerConfig.InactiveThreshold = time.Duration(stream.ConsumerInactiveThreshold) * time.Second
+	}
+	replay.apply(&consumerConfig)
+
+	consumer, err := a.js.CreateConsumer(ctx, stream.StreamName, consumerConfig)
+	if err != nil {
+		log.Error("creating consumer", zap.Error(err), zap.String("stream", stream.StreamName))
+		return datasource.NewError(fmt.Sprintf(`failed to create consumer for stream "%s"`, stream.StreamName), err)
+	}
+
+	log = log.With(zap.String("stream", stream.StreamName), zap.String("consumer", consumerConfig.Name))
+
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		a.update(log, updater, &nats.Msg{Subject: msg.Subject(), Header: msg.Headers(), Data: msg.Data()})
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		log.Warn("consuming stream", zap.Error(err))
+	}))
+	if err != nil {
+		a.deleteConsumer(log, stream.StreamName, consumerConfig.Name)
+		return datasource.NewError(fmt.Sprintf(`failed to consume stream "%s"`, stream.StreamName), err)
+	}
+
+	log.Debug("stream subscription started", zap.Uint64("replay_sequence", replay.Sequence), zap.Time("replay_time", replay.Time))
+
+	a.closeWg.Add(1)
+	go func() {
+		defer a.closeWg.Done()
+
+		select {
+		case <-a.ctx.Done():
+		case <-ctx.Done():
+		}
+
+		consumeCtx.Stop()
+		a.deleteConsumer(log, stream.StreamName, consumerConfig.Name)
+	}()
+
+	return nil
+}
+
+func (a *natsAdapter) deleteConsumer(log *zap.Logger, stream, name string) {
+	if a.client.IsClosed() {
+		// the inactive threshold removes the consumer
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.flushTimeout)
+	defer cancel()
+
+	if err := a.js.DeleteConsumer(ctx, stream, name); err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		log.Warn("deleting consumer", zap.Error(err))
+	}
+}
+
+// update sends the event to the subscribers, the events which cannot be decoded are dropped
+func (a *natsAdapter) update(log *zap.Logger, updater resolve.SubscriptionUpdater, msg *nats.Msg) {
+	data, err := psnats.ReadMsg(a.codecs, msg)
+	if err != nil {
+		log.Warn("dropping undecodable event", zap.Error(err), zap.String("message_subject", msg.Subject))
+		return
+	}
+	updater.Update(data)
+}
+
+func (a *natsAdapter) Publish(ctx context.Context, event cosmonats.PublishAndRequestEventConfiguration) error {
+	if a.client == nil {
+		return datasource.NewError("nats client not initialized", nil)
+	}
+
+	msg, err := a.newMsg(ctx, event)
+	if err == nil {
+		err = a.client.PublishMsg(msg)
+	}
+	if err != nil {
+		a.logger.Error("publish error", zap.Error(err), zap.String("provider_id", event.ProviderID), zap.String("subject", event.Subject))
+		return datasource.NewError(fmt.Sprintf("error publishing to NATS subject %s", event.Subject), err)
+	}
+
+	return nil
+}
+
+// Request writes the reply as is, the services answer with JSON whatever the encoding of the request
+func (a *natsAdapter) Request(ctx context.Context, event cosmonats.PublishAndRequestEventConfiguration, w io.Writer) error {
+	if a.client == nil {
+		return datasource.NewError("nats client not initialized", nil)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		defer cancel()
+	}
+
+	msg, err := a.newMsg(ctx, event)
+	if err != nil {
+		return datasource.NewError(fmt.Sprintf("error encoding request to NATS subject %s", event.Subject), err)
+	}
+
+	reply, err := a.client.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		a.logger.Error("request error", zap.Error(err), zap.String("provider_id", event.ProviderID), zap.String("subject", event.Subject))
+		return datasource.NewError(fmt.Sprintf("error requesting from NATS subject %s", event.Subject), err)
+	}
+
+	_, err = w.Write(reply.Data)
+	return err
+}
+
+func (a *natsAdapter) newMsg(ctx context.Context, event cosmonats.PublishAndRequestEventConfiguration) (*nats.Msg, error) {
+	return psnats.NewMsg(ctx, event.Subject, a.from, a.codecs, a.encoding, []byte(event.Data))
+}
+
+func unsubscribe(log *zap.Logger, subscriptions []*nats.Subscription) {
+	for _, subscription := range subscriptions {
+		if err := subscription.Unsubscribe(); err != nil && !errors.Is(err, nats.ErrConnectionClosed) {
+			log.Error("unsubscribing from NATS subject", zap.Error(err), zap.String("subscription_subject", subscription.Subject))
+		}
+	}
+}
+
+// consumerName keeps the consumer name of the schema as a prefix, so the consumers of a field can be told apart
+func consumerName(prefix string) string {
+	if prefix == "" {
+		return nuid.Next()
+	}
+	return prefix + "-" + nuid.Next()
+}
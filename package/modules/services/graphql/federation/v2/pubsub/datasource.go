@@ -0,0 +1,136 @@
+package fpubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+	pubsub_datasource "github.com/wundergraph/cosmo/router/pkg/pubsub/datasource"
+	cosmonats "github.com/wundergraph/cosmo/router/pkg/pubsub/nats"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+// engineDataSourceFactory extends the cosmo factory with the replay of the stream subscriptions
+type engineDataSourceFactory struct {
+	*cosmonats.EngineDataSourceFactory
+
+	adapter *natsAdapter
+	stream  bool
+
+	// replay templates, rendered from the subscription arguments
+	replaySequence string
+	replayTime     string
+}
+
+type replayInput struct {
+	Sequence string `json:"sequence,omitempty"`
+	Time     string `json:"time,omitempty"`
+}
+
+type subscriptionInput struct {
+	cosmonats.SubscriptionEventConfiguration
+	Replay *replayInput `json:"replay,omitempty"`
+}
+
+func (f *engineDataSourceFactory) TransformEventData(extractFn pubsub_datasource.ArgumentTemplateCallback) error {
+	if err := f.EngineDataSourceFactory.TransformEventData(extractFn); err != nil {
+		return err
+	}
+
+	if !f.stream {
+		return nil
+	}
+
+	// the replay arguments are optional, the callback fails when the field or the operation does not define them
+	if sequence, err := extractFn(argumentTemplate(ReplaySequenceArgument)); err == nil {
+		f.replaySequence = sequence
+	}
+	if from, err := extractFn(argumentTemplate(ReplayTimeArgument)); err == nil {
+		f.replayTime = from
+	}
+
+	return nil
+}
+
+func (f *engineDataSourceFactory) ResolveDataSourceSubscription() (resolve.SubscriptionDataSource, error) {
+	return &subscriptionSource{adapter: f.adapter}, nil
+}
+
+func (f *engineDataSourceFactory) ResolveDataSourceSubscriptionInput() (string, error) {
+	object, err := f.EngineDataSourceFactory.ResolveDataSourceSubscriptionInput()
+	if err != nil || (f.replaySequence == "" && f.replayTime == "") {
+		return object, err
+	}
+
+	var input subscriptionInput
+	if err := json.Unmarshal([]byte(object), &input.SubscriptionEventConfiguration); err != nil {
+		return "", err
+	}
+	input.Replay = &replayInput{
+		Sequence: f.replaySequence,
+		Time:     f.replayTime,
+	}
+
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal event subscription replay")
+	}
+	return string(data), nil
+}
+
+// subscriptionSource starts the subscriptions on our adapter, with the replay of the arguments or of the context
+type subscriptionSource struct {
+	adapter *natsAdapter
+}
+
+func (s *subscriptionSource) UniqueRequestID(ctx *resolve.Context, input []byte, xxh *xxhash.Digest) error {
+	event, replay, err := s.parse(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(xxh).Encode(event.Subjects); err != nil {
+		return err
+	}
+	if _, err := xxh.WriteString(event.ProviderID); err != nil {
+		return err
+	}
+
+	// replaying subscriptions get their own trigger, the live ones keep sharing theirs
+	if event.StreamConfiguration == nil || replay.IsZero() {
+		return nil
+	}
+	_, err = xxh.WriteString(strconv.FormatUint(replay.Sequence, 10) + replay.Time.String())
+	return err
+}
+
+func (s *subscriptionSource) Start(ctx *resolve.Context, input []byte, updater resolve.SubscriptionUpdater) error {
+	event, replay, err := s.parse(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	return s.adapter.subscribe(ctx.Context(), event, replay, updater)
+}
+
+func (s *subscriptionSource) parse(ctx *resolve.Context, input []byte) (cosmonats.SubscriptionEventConfiguration, Replay, error) {
+	var in subscriptionInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return cosmonats.SubscriptionEventConfiguration{}, Replay{}, err
+	}
+
+	var replay Replay
+	if in.Replay != nil {
+		var err error
+		if replay, err = ParseReplay(in.Replay.Sequence, in.Replay.Time); err != nil {
+			return cosmonats.SubscriptionEventConfiguration{}, Replay{}, err
+		}
+	}
+
+	return in.SubscriptionEventConfiguration, replay.or(replayFromContext(ctx.Context())), nil
+}
+
+func argumentTemplate(name string) string {
+	return "{{ args." + name + " }}"
+}
@@ -0,0 +1,157 @@
+package fpubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
+	routerCfg "github.com/wundergraph/cosmo/router/pkg/config"
+	pubsub_datasource "github.com/wundergraph/cosmo/router/pkg/pubsub/datasource"
+	cosmonats "github.com/wundergraph/cosmo/router/pkg/pubsub/nats"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natsconn"
+)
+
+// natsProviderBuilder connects the EDFS providers with the gateway options (credentials, TLS)
+// and prefixes the subjects of the schema with the base path of their provider.
+// The cosmo builder is kept for the data source factories, their adapter is replaced by ours,
+// which runs the stream subscriptions on ephemeral consumers able to replay the stream.
+type natsProviderBuilder struct {
+	cosmo pubsub_datasource.ProviderBuilder[routerCfg.NatsEventSource, *nodev1.NatsEventConfiguration]
+
+	ctx              context.Context
+	logger           *zap.Logger
+	hostName         string
+	routerListenAddr string
+
+	providers map[string]config.EventProviderConfig
+	adapters  map[string]*natsAdapter
+}
+
+var _ pubsub_datasource.ProviderBuilder[config.EventProviderConfig, *nodev1.NatsEventConfiguration] = (*natsProviderBuilder)(nil)
+
+func newNatsProviderBuilder(ctx context.Context, logger *zap.Logger, hostName, routerListenAddr string) *natsProviderBuilder {
+	return &natsProviderBuilder{
+		cosmo:            cosmonats.NewProviderBuilder(ctx, logger, hostName, routerListenAddr),
+		ctx:              ctx,
+		logger:           logger,
+		hostName:         hostName,
+		routerListenAddr: routerListenAddr,
+		providers:        make(map[string]config.EventProviderConfig),
+		adapters:         make(map[string]*natsAdapter),
+	}
+}
+
+func (b *natsProviderBuilder) TypeID() string {
+	return b.cosmo.TypeID()
+}
+
+func (b *natsProviderBuilder) BuildProvider(provider config.EventProviderConfig) (pubsub_datasource.Provider, error) {
+	// the cosmo adapter registered here never connects, it only lets the cosmo builder create the factories
+	if _, err := b.cosmo.BuildProvider(routerCfg.NatsEventSource{ID: provider.ID, URL: provider.URL}); err != nil {
+		return nil, err
+	}
+
+	options, err := natsOptions(provider, b.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build options for Nats provider with ID \"%s\": %w", provider.ID, err)
+	}
+
+	adapter := newNatsAdapter(b.ctx, b.logger, provider, options)
+
+	b.providers[provider.ID] = provider
+	b.adapters[provider.ID] = adapter
+
+	return pubsub_datasource.NewPubSubProvider(provider.ID, b.TypeID(), adapter, b.logger), nil
+}
+
+func (b *natsProviderBuilder) BuildEngineDataSourceFactory(data *nodev1.NatsEventConfiguration) (pubsub_datasource.EngineDataSourceFactory, error) {
+	providerID := data.GetEngineEventConfiguration().GetProviderId()
+	provider, ok := b.providers[providerID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get adapter for provider %s with ID %s", b.TypeID(), providerID)
+	}
+
+	if data.GetStreamConfiguration() != nil && provider.Type != config.EventProviderTypeJetStream {
+		return nil, &ProviderTypeError{
+			ProviderID:   providerID,
+			FieldName:    data.GetEngineEventConfiguration().GetFieldName(),
+			ExpectedType: config.EventProviderTypeJetStream,
+		}
+	}
+
+	if provider.BasePath != "" {
+		data = proto.Clone(data).(*nodev1.NatsEventConfiguration)
+		for i, subject := range data.Subjects {
+			data.Subjects[i] = strings.Join([]string{provider.BasePath, subject}, ".")
+		}
+	}
+
+	factory, err := b.cosmo.BuildEngineDataSourceFactory(data)
+	if err != nil {
+		return nil, err
+	}
+
+	natsFactory, ok := factory.(*cosmonats.EngineDataSourceFactory)
+	if !ok {
+		return nil, fmt.Errorf("unexpected data source factory %T for provider %s", factory, providerID)
+	}
+	adapter := b.adapters[providerID]
+	natsFactory.NatsAdapter = adapter
+
+	return &engineDataSourceFactory{
+		EngineDataSourceFactory: natsFactory,
+		adapter:                 adapter,
+		stream:                  data.GetStreamConfiguration() != nil,
+	}, nil
+}
+
+func natsOptions(provider config.EventProviderConfig, logger *zap.Logger) ([]nats.Option, error) {
+	name := provider.ClientName
+	if name == "" {
+		name = fmt.Sprintf("federation.edfs.nats.%s", provider.ID)
+	}
+
+	opts := []nats.Option{
+		nats.Name(name),
+		nats.MaxReconnects(-1),
+		nats.ReconnectJitter(500*time.Millisecond, 2*time.Second),
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			logger.Info("NATS connection established", zap.String("provider_id", provider.ID), zap.String("url", nc.ConnectedUrlRedacted()))
+		}),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			if err != nil {
+				logger.Error("NATS disconnected; will attempt to reconnect", zap.Error(err), zap.String("provider_id", provider.ID))
+			} else {
+				logger.Info("NATS disconnected", zap.String("provider_id", provider.ID))
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Info("NATS reconnected", zap.String("provider_id", provider.ID), zap.String("url", nc.ConnectedUrlRedacted()))
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			logger.Info("NATS connection closed", zap.String("provider_id", provider.ID), zap.Error(nc.LastError()))
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			if errors.Is(err, nats.ErrSlowConsumer) {
+				logger.Warn("NATS slow consumer detected, events are being dropped", zap.Error(err), zap.String("provider_id", provider.ID))
+				return
+			}
+			logger.Error("NATS error", zap.Error(err), zap.String("provider_id", provider.ID))
+		}),
+	}
+
+	connOpts, err := natsconn.Options(provider.Authentication, provider.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(opts, connOpts...), nil
+}
@@ -2,16 +2,16 @@ package fpubsub
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"strconv"
 
 	nodev1 "github.com/wundergraph/cosmo/router/gen/proto/wg/cosmo/node/v1"
-	"github.com/wundergraph/cosmo/router/pkg/config"
 	pubsub_datasource "github.com/wundergraph/cosmo/router/pkg/pubsub/datasource"
-	"github.com/wundergraph/cosmo/router/pkg/pubsub/nats"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
 
+	"github.com/gianglt2198/federation-go/package/config"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 )
 
@@ -39,6 +39,13 @@ type ProviderNotDefinedError struct {
 	ProviderTypeID string
 }
 
+// ProviderTypeError is returned when a field needs a provider feature its provider type does not offer
+type ProviderTypeError struct {
+	ProviderID   string
+	FieldName    string
+	ExpectedType string
+}
+
 type dsConfAndEvents[E GetEngineEventConfiguration] struct {
 	dsConf *DataSourceConfigurationWithMetadata
 	events []E
@@ -48,11 +55,22 @@ func (e *ProviderNotDefinedError) Error() string {
 	return fmt.Sprintf("%s provider with ID %s is not defined", e.ProviderTypeID, e.ProviderID)
 }
 
+func (e *ProviderTypeError) Error() string {
+	return fmt.Sprintf("field %s requires a %s provider but provider with ID %s is not", e.FieldName, e.ExpectedType, e.ProviderID)
+}
+
+// IsConfigurationError reports whether the schema references event providers which are not properly configured
+func IsConfigurationError(err error) bool {
+	var notDefined *ProviderNotDefinedError
+	var wrongType *ProviderTypeError
+	return errors.As(err, &notDefined) || errors.As(err, &wrongType)
+}
+
 // BuildProvidersAndDataSources is a generic function that builds providers and data sources for the given
-// EventsConfiguration and DataSourceConfigurationWithMetadata
+// EventsConfig and DataSourceConfigurationWithMetadata
 func BuildProvidersAndDataSources(
 	ctx context.Context,
-	config config.EventsConfiguration,
+	eventsConfig config.EventsConfig,
 	logger *logging.Logger,
 	dsConfs []DataSourceConfigurationWithMetadata,
 	hostName string,
@@ -62,7 +80,7 @@ func BuildProvidersAndDataSources(
 	var outs []plan.DataSource
 
 	// initialize NATS providers and data sources
-	natsBuilder := nats.NewProviderBuilder(ctx, logger.GetLogger(), hostName, routerListenAddr)
+	natsBuilder := newNatsProviderBuilder(ctx, logger.GetLogger(), hostName, routerListenAddr)
 	natsDsConfsWithEvents := []dsConfAndEvents[*nodev1.NatsEventConfiguration]{}
 	for _, dsConf := range dsConfs {
 		natsDsConfsWithEvents = append(natsDsConfsWithEvents, dsConfAndEvents[*nodev1.NatsEventConfiguration]{
@@ -70,7 +88,7 @@ func BuildProvidersAndDataSources(
 			events: dsConf.Configuration.GetCustomEvents().GetNats(),
 		})
 	}
-	natsPubSubProviders, natsOuts, err := build(ctx, natsBuilder, config.Providers.Nats, natsDsConfsWithEvents)
+	natsPubSubProviders, natsOuts, err := build(ctx, natsBuilder, eventsConfig.Providers, natsDsConfsWithEvents)
 	if err != nil {
 		return nil, nil, err
 	}
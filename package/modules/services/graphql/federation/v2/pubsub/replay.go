@@ -0,0 +1,113 @@
+package fpubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// ReplaySequenceArgument is the subscription argument, or connection_init replay key, of the stream sequence to resume from
+	ReplaySequenceArgument = "fromSequence"
+	// ReplayTimeArgument is the subscription argument, or connection_init replay key, of the RFC 3339 time to resume from
+	ReplayTimeArgument = "fromTime"
+
+	replayInitPayloadKey = "replay"
+)
+
+// Replay is the position a stream subscription starts from, the zero value delivers the new messages only
+type Replay struct {
+	Sequence uint64
+	Time     time.Time
+}
+
+type replayCtxKey struct{}
+
+// WithReplay sets the replay of the stream subscriptions started with the context,
+// the replay arguments of a subscription take precedence over it
+func WithReplay(ctx context.Context, replay Replay) context.Context {
+	return context.WithValue(ctx, replayCtxKey{}, replay)
+}
+
+func replayFromContext(ctx context.Context) Replay {
+	replay, _ := ctx.Value(replayCtxKey{}).(Replay)
+	return replay
+}
+
+// ParseReplay reads the replay arguments of a subscription, empty or null values are ignored
+func ParseReplay(sequence, from string) (Replay, error) {
+	var replay Replay
+
+	if sequence != "" && sequence != "null" {
+		seq, err := strconv.ParseUint(sequence, 10, 64)
+		if err != nil {
+			return Replay{}, fmt.Errorf("invalid %s %q: %w", ReplaySequenceArgument, sequence, err)
+		}
+		replay.Sequence = seq
+	}
+
+	if from != "" && from != "null" {
+		t, err := time.Parse(time.RFC3339Nano, from)
+		if err != nil {
+			return Replay{}, fmt.Errorf("invalid %s %q: %w", ReplayTimeArgument, from, err)
+		}
+		replay.Time = t
+	}
+
+	return replay, nil
+}
+
+// ReplayFromInitPayload reads the replay of the connection_init payload, e.g. {"replay": {"fromSequence": 42}}
+func ReplayFromInitPayload(payload json.RawMessage) (Replay, bool, error) {
+	if len(payload) == 0 {
+		return Replay{}, false, nil
+	}
+
+	var init struct {
+		Replay *struct {
+			Sequence json.Number `json:"fromSequence"`
+			Time     string      `json:"fromTime"`
+		} `json:"replay"`
+	}
+	if err := json.Unmarshal(payload, &init); err != nil || init.Replay == nil {
+		// the payload belongs to the client, only a well formed replay is considered
+		return Replay{}, false, nil
+	}
+
+	replay, err := ParseReplay(init.Replay.Sequence.String(), init.Replay.Time)
+	if err != nil {
+		return Replay{}, false, fmt.Errorf("connection_init %s: %w", replayInitPayloadKey, err)
+	}
+	return replay, !replay.IsZero(), nil
+}
+
+func (r Replay) IsZero() bool {
+	return r.Sequence == 0 && r.Time.IsZero()
+}
+
+// or returns the replay, or the fallback when the replay is not set
+func (r Replay) or(fallback Replay) Replay {
+	if r.IsZero() {
+		return fallback
+	}
+	return r
+}
+
+// apply sets the deliver policy of the consumer, the sequence wins over the time
+func (r Replay) apply(cfg *jetstream.ConsumerConfig) {
+	switch {
+	case r.Sequence > 0:
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = r.Sequence
+	case !r.Time.IsZero():
+		cfg.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		startTime := r.Time
+		cfg.OptStartTime = &startTime
+	default:
+		cfg.DeliverPolicy = jetstream.DeliverNewPolicy
+	}
+}
@@ -0,0 +1,364 @@
+package fpubsub
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	cosmonats "github.com/wundergraph/cosmo/router/pkg/pubsub/nats"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"go.uber.org/zap"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/nats/natstest"
+)
+
+const (
+	waitTimeout = 5 * time.Second
+	stream      = "EVENTS"
+)
+
+type updater struct {
+	updates chan []byte
+}
+
+func newUpdater() *updater {
+	return &updater{updates: make(chan []byte, 16)}
+}
+
+func (u *updater) Update(data []byte) {
+	u.updates <- append([]byte(nil), data...)
+}
+
+func (u *updater) Complete() {}
+
+func (u *updater) Close(resolve.SubscriptionCloseKind) {}
+
+// next returns the next update, nil when none comes in the wait
+func (u *updater) next(wait time.Duration) []byte {
+	select {
+	case data := <-u.updates:
+		return data
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// newPublisher is the client of a service, creating the stream of the configuration
+func newPublisher(t *testing.T, cfg config.NATSConfig) pubsub.Publisher {
+	t.Helper()
+
+	cfg.ContentType = config.EventEncodingJSON
+	log, err := logging.NewLogger(config.AppConfig{Name: cfg.Name}, config.NATSConfig{})
+	if err != nil {
+		t.Fatalf("logger: %v", err)
+	}
+	client, err := psnats.New(psnats.NatsParams{Log: log, Config: cfg})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func newAdapter(t *testing.T, cfg config.NATSConfig) *natsAdapter {
+	t.Helper()
+
+	provider := config.EventProviderConfig{
+		ID:       "default",
+		Type:     config.EventProviderTypeJetStream,
+		URL:      cfg.URLs(),
+		Encoding: config.EventEncodingJSON,
+	}
+	adapter := newNatsAdapter(context.Background(), zap.NewNop(), provider, nil)
+	if err := adapter.Startup(context.Background()); err != nil {
+		t.Fatalf("startup: %v", err)
+	}
+	t.Cleanup(func() { _ = adapter.Shutdown(context.Background()) })
+
+	return adapter
+}
+
+// publish sends the events and waits for the stream to store them
+func publish(t *testing.T, publisher pubsub.Publisher, adapter *natsAdapter, subject string, events ...string) {
+	t.Helper()
+
+	ctx := context.Background()
+	s, err := adapter.js.Stream(ctx, stream)
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	info, err := s.Info(ctx)
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	want := info.State.Msgs + uint64(len(events))
+
+	for _, event := range events {
+		if err := publisher.Publish(ctx, subject, []byte(event), nil); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		info, err := s.Info(ctx)
+		if err != nil {
+			t.Fatalf("stream info: %v", err)
+		}
+		if info.State.Msgs == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream messages = %d, want %d", info.State.Msgs, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func streamEvent(subjects ...string) cosmonats.SubscriptionEventConfiguration {
+	return cosmonats.SubscriptionEventConfiguration{
+		ProviderID:          "default",
+		Subjects:            subjects,
+		StreamConfiguration: &cosmonats.StreamConfiguration{StreamName: stream, Consumer: "userUpdated"},
+	}
+}
+
+func expectUpdates(t *testing.T, u *updater, want ...string) {
+	t.Helper()
+
+	for _, event := range want {
+		data := u.next(waitTimeout)
+		if data == nil {
+			t.Fatalf("update %s not received", event)
+		}
+		if string(data) != event {
+			t.Fatalf("update = %s, want %s", data, event)
+		}
+	}
+	if data := u.next(100 * time.Millisecond); data != nil {
+		t.Fatalf("unexpected update %s", data)
+	}
+}
+
+func TestStreamSubscriptionReplaysFromSequence(t *testing.T) {
+	cfg := natstest.Start(t, natstest.WithJetStream(stream, "userUpdated.>"))
+	publisher := newPublisher(t, cfg)
+	adapter := newAdapter(t, cfg)
+
+	// sequences 1 to 4, the third one of another user
+	publish(t, publisher, adapter, "userUpdated.u1", `{"n":1}`, `{"n":2}`)
+	publish(t, publisher, adapter, "userUpdated.u2", `{"n":3}`)
+	publish(t, publisher, adapter, "userUpdated.u1", `{"n":4}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	u := newUpdater()
+	if err := adapter.subscribe(ctx, streamEvent("test.userUpdated.u1"), Replay{Sequence: 2}, u); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	expectUpdates(t, u, `{"n":2}`, `{"n":4}`)
+
+	publish(t, publisher, adapter, "userUpdated.u1", `{"n":5}`)
+	expectUpdates(t, u, `{"n":5}`)
+}
+
+func TestStreamSubscriptionReplaysFromTime(t *testing.T) {
+	cfg := natstest.Start(t, natstest.WithJetStream(stream, "userUpdated.>"))
+	publisher := newPublisher(t, cfg)
+	adapter := newAdapter(t, cfg)
+
+	publish(t, publisher, adapter, "userUpdated.u1", `{"n":1}`)
+	time.Sleep(10 * time.Millisecond)
+	from := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	publish(t, publisher, adapter, "userUpdated.u1", `{"n":2}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	u := newUpdater()
+	if err := adapter.subscribe(ctx, streamEvent("test.userUpdated.*"), Replay{Time: from}, u); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	expectUpdates(t, u, `{"n":2}`)
+}
+
+func TestStreamSubscriptionWithoutReplayDeliversNewEvents(t *testing.T) {
+	cfg := natstest.Start(t, natstest.WithJetStream(stream, "userUpdated.>"))
+	publisher := newPublisher(t, cfg)
+	adapter := newAdapter(t, cfg)
+
+	publish(t, publisher, adapter, "userUpdated.u1", `{"n":1}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	u := newUpdater()
+	if err := adapter.subscribe(ctx, streamEvent("test.userUpdated.u1"), Replay{}, u); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	publish(t, publisher, adapter, "userUpdated.u2", `{"n":2}`)
+	publish(t, publisher, adapter, "userUpdated.u1", `{"n":3}`)
+	expectUpdates(t, u, `{"n":3}`)
+}
+
+func TestStreamSubscriptionDeletesItsConsumer(t *testing.T) {
+	cfg := natstest.Start(t, natstest.WithJetStream(stream, "userUpdated.>"))
+	newPublisher(t, cfg)
+	adapter := newAdapter(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := adapter.subscribe(ctx, streamEvent("test.userUpdated.u1"), Replay{}, newUpdater()); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	if got := consumers(t, adapter.js); got != 1 {
+		t.Fatalf("consumers = %d, want the one of the subscription", got)
+	}
+
+	cancel()
+
+	deadline := time.Now().Add(waitTimeout)
+	for consumers(t, adapter.js) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("consumer not deleted once the subscription ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func consumers(t *testing.T, js jetstream.JetStream) int {
+	t.Helper()
+
+	s, err := js.Stream(context.Background(), stream)
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	info, err := s.Info(context.Background())
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	return info.State.Consumers
+}
+
+func TestCoreSubscriptionFiltersSubjects(t *testing.T) {
+	cfg := natstest.Start(t)
+	adapter := newAdapter(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	u := newUpdater()
+	event := cosmonats.SubscriptionEventConfiguration{ProviderID: "default", Subjects: []string{"test.userUpdated.u1"}}
+	if err := adapter.subscribe(ctx, event, Replay{}, u); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := adapter.client.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	for subject, data := range map[string]string{"test.userUpdated.u2": `{"n":1}`, "test.userUpdated.u1": `{"n":2}`} {
+		msg := nats.NewMsg(subject)
+		msg.Header.Set(psnats.HeaderContentType, "application/json")
+		msg.Data = []byte(data)
+		if err := adapter.client.PublishMsg(msg); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	expectUpdates(t, u, `{"n":2}`)
+}
+
+func TestParseReplay(t *testing.T) {
+	from := "2026-10-18T10:00:00Z"
+
+	tests := []struct {
+		name     string
+		sequence string
+		from     string
+		want     Replay
+		wantErr  bool
+	}{
+		{name: "none", want: Replay{}},
+		{name: "null arguments", sequence: "null", from: "null", want: Replay{}},
+		{name: "sequence", sequence: "42", want: Replay{Sequence: 42}},
+		{name: "time", from: from, want: Replay{Time: time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)}},
+		{name: "invalid sequence", sequence: "-1", wantErr: true},
+		{name: "invalid time", from: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReplay(tt.sequence, tt.from)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !got.Time.Equal(tt.want.Time) || got.Sequence != tt.want.Sequence {
+				t.Fatalf("replay = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayFromInitPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Replay
+		found   bool
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "no replay", payload: `{"Authorization":"Bearer token"}`},
+		{name: "not an object", payload: `"token"`},
+		{name: "sequence", payload: `{"replay":{"fromSequence":42}}`, want: Replay{Sequence: 42}, found: true},
+		{name: "zero replay", payload: `{"replay":{}}`},
+		{name: "invalid time", payload: `{"replay":{"fromTime":"yesterday"}}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := ReplayFromInitPayload(json.RawMessage(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if found != tt.found || got != tt.want {
+				t.Fatalf("replay = %+v %v, want %+v %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestReplayApply(t *testing.T) {
+	from := time.Now()
+
+	var cfg jetstream.ConsumerConfig
+	Replay{Sequence: 7, Time: from}.apply(&cfg)
+	if cfg.DeliverPolicy != jetstream.DeliverByStartSequencePolicy || cfg.OptStartSeq != 7 {
+		t.Fatalf("sequence replay = %v from %d, want the sequence to win over the time", cfg.DeliverPolicy, cfg.OptStartSeq)
+	}
+
+	cfg = jetstream.ConsumerConfig{}
+	Replay{Time: from}.apply(&cfg)
+	if cfg.DeliverPolicy != jetstream.DeliverByStartTimePolicy || cfg.OptStartTime == nil || !cfg.OptStartTime.Equal(from) {
+		t.Fatalf("time replay = %v from %v", cfg.DeliverPolicy, cfg.OptStartTime)
+	}
+
+	cfg = jetstream.ConsumerConfig{}
+	Replay{}.apply(&cfg)
+	if cfg.DeliverPolicy != jetstream.DeliverNewPolicy {
+		t.Fatalf("no replay = %v, want the new messages only", cfg.DeliverPolicy)
+	}
+}
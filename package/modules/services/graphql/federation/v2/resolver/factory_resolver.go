@@ -13,6 +13,7 @@ import (
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/infras/pubsub"
 	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/types"
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
 	"github.com/gianglt2198/federation-go/package/modules/services/http/transports"
 )
 
@@ -25,6 +26,8 @@ type DefaultFactoryResolver struct {
 	streamingClient    *http.Client
 	subscriptionClient graphql_datasource.GraphQLSubscriptionClient
 
+	plugins plugin.Chain
+
 	factoryLogger abstractlogger.Logger
 	instanceData  types.InstanceData
 }
@@ -35,6 +38,7 @@ func NewDefaultFactoryResolver(
 	enableNetPoll bool,
 	instanceData types.InstanceData,
 	broker pubsub.Broker,
+	plugins []plugin.Plugin,
 ) *DefaultFactoryResolver {
 	// Create HTTP client with custom transport for NATS support
 	transport := transports.NewNatsTransport(transports.NatsTransportParams{
@@ -86,11 +90,23 @@ func NewDefaultFactoryResolver(
 		httpClient: defaultHTTPClient,
 
 		instanceData: instanceData,
+
+		plugins: plugins,
 	}
 }
 
 func (d *DefaultFactoryResolver) ResolveGraphqlFactory(subgraphName string) (plan.PlannerFactory[graphql_datasource.Configuration], error) {
-	return graphql_datasource.NewFactory(d.engineCtx, d.httpClient, d.subscriptionClient)
+	if len(d.plugins) == 0 {
+		return graphql_datasource.NewFactory(d.engineCtx, d.httpClient, d.subscriptionClient)
+	}
+
+	// each subgraph gets its own client so that plugins know which subgraph a request targets
+	httpClient := &http.Client{
+		Timeout:   d.httpClient.Timeout,
+		Transport: newPluginTransport(subgraphName, d.plugins, d.httpClient.Transport),
+	}
+
+	return graphql_datasource.NewFactory(d.engineCtx, httpClient, d.subscriptionClient)
 }
 
 func (d *DefaultFactoryResolver) InstanceData() types.InstanceData {
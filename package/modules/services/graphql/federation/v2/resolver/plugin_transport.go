@@ -0,0 +1,107 @@
+package resolver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gianglt2198/federation-go/package/modules/services/graphql/federation/v2/plugin"
+)
+
+// pluginTransport runs the subgraph hooks of the plugins around the upstream transport
+type pluginTransport struct {
+	subgraph string
+	plugins  plugin.Chain
+	upstream http.RoundTripper
+}
+
+func newPluginTransport(subgraph string, plugins plugin.Chain, upstream http.RoundTripper) *pluginTransport {
+	return &pluginTransport{
+		subgraph: subgraph,
+		plugins:  plugins,
+		upstream: upstream,
+	}
+}
+
+func (t *pluginTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// the request must not be mutated by a RoundTripper, hooks work on a clone
+	original := req
+	req = req.Clone(ctx)
+
+	body, err := readRequestBody(original, req)
+	if err != nil {
+		return nil, err
+	}
+
+	subgraphRequest := &plugin.SubgraphRequest{
+		Subgraph: t.subgraph,
+		Header:   req.Header,
+		Body:     body,
+	}
+	if err := t.plugins.OnBeforeSubgraphRequest(ctx, subgraphRequest); err != nil {
+		return nil, err
+	}
+
+	req.Header = subgraphRequest.Header
+	req.Body = io.NopCloser(bytes.NewReader(subgraphRequest.Body))
+	req.ContentLength = int64(len(subgraphRequest.Body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(subgraphRequest.Body)), nil
+	}
+
+	resp, err := t.upstream.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	subgraphResponse := &plugin.SubgraphResponse{
+		Subgraph:   t.subgraph,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       data,
+	}
+	if err := t.plugins.OnAfterSubgraphResponse(ctx, subgraphResponse); err != nil {
+		return nil, err
+	}
+
+	resp.StatusCode = subgraphResponse.StatusCode
+	resp.Status = strconv.Itoa(subgraphResponse.StatusCode) + " " + http.StatusText(subgraphResponse.StatusCode)
+	resp.Header = subgraphResponse.Header
+	resp.Body = io.NopCloser(bytes.NewReader(subgraphResponse.Body))
+	resp.ContentLength = int64(len(subgraphResponse.Body))
+	resp.Header.Del("Content-Length")
+
+	return resp, nil
+}
+
+// readRequestBody reads the body of the clone, from a copy of GetBody when the original can be replayed.
+// The RoundTripper closes the original body in any case.
+func readRequestBody(original, clone *http.Request) ([]byte, error) {
+	if original.Body != nil {
+		defer original.Body.Close()
+	}
+
+	body := clone.Body
+	if clone.GetBody != nil {
+		copied, err := clone.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer copied.Close()
+		body = copied
+	}
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+
+	return io.ReadAll(body)
+}
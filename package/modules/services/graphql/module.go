@@ -37,6 +37,7 @@ var FModuleV2 = fx.Module("federation-module-v2",
 
 func RegisterGraphQLServer(
 	Lifecycle fx.Lifecycle,
+	Shutdowner fx.Shutdowner,
 	Log *logging.Logger,
 	GraphQLService common.GraphqlServer,
 ) {
@@ -47,6 +48,7 @@ func RegisterGraphQLServer(
 			go func() {
 				if err := GraphQLService.Start(); err != nil {
 					Log.Error("Failed to start GraphQL service", zap.Error(err))
+					_ = Shutdowner.Shutdown(fx.ExitCode(1))
 				}
 			}()
 
@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"time"
 
@@ -38,10 +40,10 @@ func RegisterHandler(
 		if err := utils.JsonDecode(bodyReader, &params); err != nil {
 			gqlErr := gqlerror.Errorf("decoded err: %+v", err)
 			resp := &graphql.Response{Errors: []*gqlerror.Error{gqlErr}}
-			return resp, err
+			return rawResponse(http.StatusBadRequest, resp), err
 		}
 
-		return handleGraphql(ctx, params, exec), nil
+		return rawResponse(http.StatusOK, handleGraphql(ctx, params, exec)), nil
 	}); err != nil {
 		return err
 	}
@@ -49,6 +51,21 @@ func RegisterHandler(
 	return nil
 }
 
+// rawResponse encodes the response once, the gateway streams it back to the engine as it is
+func rawResponse(status int, resp *graphql.Response) *pubsub.Response {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		status = http.StatusInternalServerError
+		body = []byte(`{"errors":[{"message":"internal server error"}]}`)
+	}
+
+	return &pubsub.Response{
+		Status: status,
+		Header: map[string]string{"Content-Type": "application/json"},
+		Body:   body,
+	}
+}
+
 func handleGraphql(ctx context.Context, params *graphql.RawParams, exec *executor.Executor) *graphql.Response {
 	// ctx = dataloader.NewContextWithDataLoader(ctx)
 	rc, Operr := exec.CreateOperationContext(ctx, params)
@@ -2,8 +2,10 @@ package transports
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,14 +13,20 @@ import (
 
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
 	"github.com/gianglt2198/federation-go/package/utils"
 )
 
+// requestTimeout bounds the wait of each reply chunk
+const requestTimeout = 5 * time.Second
+
+// NatsTransport sends the subgraph requests over NATS, to the subject of their host
 type NatsTransport struct {
 	http.RoundTripper
 
 	logger *logging.Logger
-	broker pubsub.Broker
+	// broker is nil when the broker of the params does not stream raw replies
+	broker pubsub.RawBroker
 }
 
 type NatsTransportParams struct {
@@ -27,12 +35,15 @@ type NatsTransportParams struct {
 	Broker   pubsub.Broker
 }
 
+// NewNatsTransport needs a pubsub.RawBroker, like the NATS and the in memory ones, to pass the replies through
 func NewNatsTransport(params NatsTransportParams) *NatsTransport {
+	broker, _ := params.Broker.(pubsub.RawBroker)
+
 	params.Upstream.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	return &NatsTransport{
 		RoundTripper: params.Upstream,
 		logger:       params.Logger,
-		broker:       params.Broker,
+		broker:       broker,
 	}
 }
 
@@ -43,20 +54,60 @@ func (t *NatsTransport) RoundTrip(req *http.Request) (resp *http.Response, err e
 		return nil, fmt.Errorf("do request: %v", err)
 	}
 
-	ctx := utils.GetFiberUserContext(req.Context())
+	if t.broker == nil {
+		return nil, errors.New("do request: the broker does not stream raw replies")
+	}
 
-	var result any
-	err = t.broker.Request(ctx, req.Host, buf, nil, 5*time.Second, &result)
+	return t.roundTripRaw(utils.GetFiberUserContext(req.Context()), req, buf)
+}
+
+// roundTripRaw passes the bytes through unchanged, with the status and the headers of the subgraph.
+// The reply is streamed in chunks, so it can exceed the max payload of the server.
+func (t *NatsTransport) roundTripRaw(ctx context.Context, req *http.Request, body []byte) (*http.Response, error) {
+	res, err := t.broker.RequestRaw(ctx, req.Host, body, nil, requestTimeout)
 	if err != nil {
 		t.logger.Error(err.Error())
 		return nil, fmt.Errorf("do request: %v", err)
 	}
 
-	b, _ := json.Marshal(result)
-	resp = &http.Response{
-		Status:     http.StatusText(http.StatusOK),
-		StatusCode: http.StatusOK,
-		Body:       io.NopCloser(bytes.NewBuffer(b)),
+	header := make(http.Header, len(res.Header))
+	for k, v := range res.Header {
+		header.Set(k, v)
+	}
+
+	if res.Status >= http.StatusInternalServerError {
+		res.Body = graphQLError(res.Body)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.Status, http.StatusText(res.Status)),
+		StatusCode:    res.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}, nil
+}
+
+// graphQLError turns the ErrorResponse of a failed subgraph handler, e.g. the reply of the recovery
+// middleware, into a GraphQL error so that the client gets its code. Other bodies are kept as they are.
+func graphQLError(body []byte) []byte {
+	var resp psnats.ErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Error.Code == "" {
+		return body
+	}
+
+	b, err := json.Marshal(map[string]any{
+		"errors": []map[string]any{{
+			"message":    resp.Error.Message,
+			"extensions": map[string]any{"code": resp.Error.Code},
+		}},
+	})
+	if err != nil {
+		return body
 	}
-	return resp, nil
+	return b
 }
@@ -9,8 +9,11 @@ import (
 	"github.com/gianglt2198/federation-go/package/config"
 	"github.com/gianglt2198/federation-go/package/helpers"
 	credis "github.com/gianglt2198/federation-go/package/infras/cache/redis"
+	ctiered "github.com/gianglt2198/federation-go/package/infras/cache/tiered"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/tracing"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub/eventbus"
+	psmemory "github.com/gianglt2198/federation-go/package/infras/pubsub/memory"
 	psnats "github.com/gianglt2198/federation-go/package/infras/pubsub/nats"
 	"github.com/gianglt2198/federation-go/package/infras/serdes"
 	"github.com/gianglt2198/federation-go/package/modules/queue"
@@ -48,6 +51,7 @@ func NewApp[T any](cfg *config.Config[T], modules ...fx.Option) App {
 		fx.Supply(cfg.Encrypt),
 		fx.Supply(cfg.Tracing),
 		fx.Supply(cfg.Redis),
+		fx.Supply(cfg.Cache),
 		fx.Supply(cfg.Queue),
 		fx.Supply(cfg.Scheduler),
 		// Provide logger
@@ -56,22 +60,31 @@ func NewApp[T any](cfg *config.Config[T], modules ...fx.Option) App {
 		fx.Provide(helpers.NewJWTHelper),
 		// Provide encryptor
 		fx.Provide(helpers.NewAESCipher),
-		// Provide serializers for NATS
+		// Provide serializers for NATS, the codecs of the messages are declared by their headers
 		fx.Provide(serdes.NewMsgPack),
+		fx.Provide(func() *serdes.Registry { return serdes.NewRegistry() }),
 		// Logger configuration
 		fx.WithLogger(func(logger *logging.Logger) fxevent.Logger {
 			return logger.Fx()
 		}),
 	}
 
-	// Provide NATS connection
-	coreModules = append(coreModules, psnats.Module...)
+	// Provide NATS connection, or its in memory counterpart
+	if cfg.NATS.Driver == config.NATSDriverMemory {
+		coreModules = append(coreModules, psmemory.Module...)
+	} else {
+		coreModules = append(coreModules, psnats.Module...)
+	}
+	// Provide typed event bus
+	coreModules = append(coreModules, eventbus.Module...)
 	// Provide HTTP server
 	coreModules = append(coreModules, httpservice.Module...)
 	// Provide Tracing Client
 	coreModules = append(coreModules, tracing.Module...)
 	// Provide Redis Client
 	coreModules = append(coreModules, credis.Module...)
+	// Provide Cache, local and/or Redis
+	coreModules = append(coreModules, ctiered.Module...)
 	// Provide Queue Client
 	coreModules = append(coreModules, queue.Module...)
 	// Provide Scheduler Client
@@ -26,6 +26,14 @@ func GetUserIDFromCtx(ctx context.Context) string {
 	return userID
 }
 
+func GetTenantIDFromCtx(ctx context.Context) string {
+	tenantID, ok := ctx.Value(common.KEY_TENANT_ID).(string)
+	if !ok {
+		return ""
+	}
+	return tenantID
+}
+
 func GetTraceIDFromCtx(ctx context.Context) string {
 	traceID, ok := ctx.Value(common.KEY_TRACE_ID).(string)
 	if !ok {
@@ -85,6 +93,10 @@ func ApplyUserIDWithContext(ctx context.Context, userID string) context.Context
 	return context.WithValue(ctx, common.KEY_AUTH_USER_ID, userID)
 }
 
+func ApplyTenantIDWithContext(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, common.KEY_TENANT_ID, tenantID)
+}
+
 func GetFiberUserContext(ctx context.Context) context.Context {
 	userContext, ok := ctx.Value("__local_user_context__").(context.Context)
 	if ok {
@@ -0,0 +1,85 @@
+package utils
+
+// MatchGlob reports whether the key matches the pattern with the glob syntax of Redis:
+// * matches any sequence, ? any character, [abc], [^abc] and [a-z] a set, and \ escapes the next character
+func MatchGlob(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if MatchGlob(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			end, ok := matchSet(pattern, key[0])
+			if !ok {
+				return false
+			}
+			pattern = pattern[end:]
+			key = key[1:]
+			continue
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		key = key[1:]
+	}
+	return len(key) == 0
+}
+
+// matchSet matches c against the set opening the pattern, and returns the length of the set
+func matchSet(pattern string, c byte) (int, bool) {
+	i := 1
+	negate := i < len(pattern) && pattern[i] == '^'
+	if negate {
+		i++
+	}
+
+	matched := false
+	for ; i < len(pattern) && pattern[i] != ']'; i++ {
+		lo := pattern[i]
+		if lo == '\\' && i+1 < len(pattern) {
+			i++
+			lo = pattern[i]
+		}
+		hi := lo
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			hi = pattern[i+2]
+			i += 2
+		}
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if c >= lo && c <= hi {
+			matched = true
+		}
+	}
+	// an unterminated set matches up to the end of the pattern, like Redis
+	if i < len(pattern) {
+		i++
+	}
+
+	return i, matched != negate
+}
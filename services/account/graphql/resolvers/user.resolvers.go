@@ -9,8 +9,13 @@ import (
 	"github.com/gianglt2198/federation-go/services/account/generated/graph/model"
 )
 
-func (r *entityResolver) FindUserEntityByID(ctx context.Context, id string) (*model.UserEntity, error) {
-	return r.userService.FindUserByID(ctx, id)
+// FindManyUserEntityByIDs resolves the user references of the other subgraphs in a single query.
+func (r *entityResolver) FindManyUserEntityByIDs(ctx context.Context, reps []*model.UserEntityByIDsInput) ([]*model.UserEntity, error) {
+	ids := make([]string, len(reps))
+	for i, rep := range reps {
+		ids[i] = rep.ID
+	}
+	return r.userService.FindUsersByIDs(ctx, ids)
 }
 
 // Users is the resolver for the users field.
@@ -1,6 +1,9 @@
 package infra
 
 import (
+	stdsql "database/sql"
+
+	"entgo.io/ent/dialect"
 	"entgo.io/ent/dialect/sql"
 	_ "github.com/lib/pq"
 	"go.uber.org/fx"
@@ -10,22 +13,40 @@ import (
 	"github.com/gianglt2198/federation-go/package/infras/pubsub"
 	"github.com/gianglt2198/federation-go/package/modules/db"
 	"github.com/gianglt2198/federation-go/package/modules/db/hooks"
+	"github.com/gianglt2198/federation-go/package/modules/db/outbox"
 
 	"github.com/gianglt2198/federation-go/services/account/generated/ent"
 )
 
 var dbModule = fx.Module("db",
 	fx.Provide(NewDB),
+	outbox.Module,
 )
 
+type DBResult struct {
+	fx.Out
+
+	Client *ent.Client
+	// DB is shared with the outbox relay
+	DB *stdsql.DB
+}
+
 func NewDB(
 	cfg config.DatabaseConfig,
 	appCfg config.AppConfig,
 	logger *logging.Logger,
 	publisher pubsub.Publisher,
-) *ent.Client {
+) DBResult {
+	driver := sql.OpenDB(cfg.Driver, db.NewDB(cfg, logger))
+
+	var entDriver dialect.Driver = driver
+	if cfg.Outbox.Enabled {
+		// the outbox hooks run the mutations and the writes of their events in a transaction of this driver
+		entDriver = outbox.NewDriver(driver)
+	}
+
 	opts := []ent.Option{
-		ent.Driver(sql.OpenDB(cfg.Driver, db.NewDB(cfg, logger))),
+		ent.Driver(entDriver),
 	}
 
 	if cfg.Debug {
@@ -34,7 +55,20 @@ func NewDB(
 
 	client := ent.NewClient(opts...)
 
-	client.Use(hooks.PublishEntityChangeHook(appCfg.Name, publisher, logger))
+	if cfg.Outbox.Enabled {
+		client.Use(
+			outbox.Hook(appCfg.Name),
+			outbox.FederationHook(logger, cfg.EntityEvents),
+		)
+	} else {
+		client.Use(
+			hooks.PublishEntityChangeHook(appCfg.Name, publisher, logger),
+			hooks.PublishFederationEventHook(publisher, logger, cfg.EntityEvents),
+		)
+	}
 
-	return client
+	return DBResult{
+		Client: client,
+		DB:     driver.DB(),
+	}
 }
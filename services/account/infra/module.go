@@ -4,6 +4,7 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/gianglt2198/federation-go/services/account/graphql"
+	"github.com/gianglt2198/federation-go/services/account/internal/events"
 	"github.com/gianglt2198/federation-go/services/account/internal/repos"
 	"github.com/gianglt2198/federation-go/services/account/internal/services"
 )
@@ -13,4 +14,5 @@ var Module = fx.Module("infra",
 	repos.Module,
 	services.Module,
 	graphql.Module,
+	events.Module,
 )
@@ -0,0 +1,7 @@
+package events
+
+import "go.uber.org/fx"
+
+var Module = fx.Module("events",
+	fx.Invoke(RegisterUserHandlers),
+)
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"go.uber.org/fx"
+
+	"github.com/gianglt2198/federation-go/package/config"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
+
+	"github.com/gianglt2198/federation-go/services/account/generated/graph/model"
+	"github.com/gianglt2198/federation-go/services/account/internal/services"
+)
+
+// UserFindSubject is requested by the userByID query of the gateway through @edfs__natsRequest.
+// Any user is answered to any caller, so the query is tagged internal and only exposed by the admin contract.
+const UserFindSubject = "account.user.find"
+
+type UserHandlersParams struct {
+	fx.In
+
+	Lc         fx.Lifecycle
+	AppConfig  config.AppConfig
+	NATSConfig config.NATSConfig
+
+	Subscriber  pubsub.QueueSubscriber
+	UserService services.UserService
+}
+
+func RegisterUserHandlers(params UserHandlersParams) {
+	if !params.NATSConfig.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	params.Lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			return params.Subscriber.QueueSubscribe(ctx, UserFindSubject, params.AppConfig.Name, findUser(params.UserService))
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
+
+type findUserRequest struct {
+	ID string `json:"id"`
+}
+
+// findUser answers with the user as JSON. On error the typed nil is still sent, as null,
+// so that the gateway fails the field right away instead of waiting for the request timeout.
+func findUser(userService services.UserService) pubsub.Handler {
+	return func(ctx context.Context, msg pubsub.Message) (any, error) {
+		var req findUserRequest
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return (*model.UserEntity)(nil), err
+		}
+
+		user, err := userService.FindUserByID(ctx, req.ID)
+		if err != nil {
+			return (*model.UserEntity)(nil), err
+		}
+		return user, nil
+	}
+}
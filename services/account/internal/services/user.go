@@ -27,6 +27,7 @@ type (
 	UserService interface {
 		FindUserByID(ctx context.Context, id string) (*model.UserEntity, error)
 		FindUserByEmail(ctx context.Context, email string) (*model.UserEntity, error)
+		FindUsersByIDs(ctx context.Context, ids []string) ([]*model.UserEntity, error)
 		FindUsers(ctx context.Context, after *entgql.Cursor[string], first *int, before *entgql.Cursor[string], last *int, orderBy []*ent.UserOrder, where *model.UserFilter) (*model.UserPaginatedConnection, error)
 
 		CreateUser(ctx context.Context, input ent.CreateUserInput) (*ent.User, error)
@@ -72,6 +73,32 @@ func (s *userService) FindUserByID(ctx context.Context, id string) (*model.UserE
 	return userEntity, nil
 }
 
+// FindUsersByIDs returns the users in the order of the given ids, with nil for the unknown ones
+func (s *userService) FindUsersByIDs(ctx context.Context, ids []string) ([]*model.UserEntity, error) {
+	users, err := s.userRepository.FindAllWithPredicates(ctx, s.userRepository.WithCollectFields(ctx), user.IDIn(lo.Uniq(ids)...))
+	if err != nil {
+		return nil, err
+	}
+
+	usersByID := lo.KeyBy(users, func(u *ent.User) string { return u.ID })
+
+	userEntities := make([]*model.UserEntity, len(ids))
+	for i, id := range ids {
+		u, ok := usersByID[id]
+		if !ok {
+			continue
+		}
+
+		userEntity, err := utils.ConvertTo[ent.User, model.UserEntity](u)
+		if err != nil {
+			return nil, err
+		}
+		userEntities[i] = userEntity
+	}
+
+	return userEntities, nil
+}
+
 func (s *userService) FindUserByEmail(ctx context.Context, email string) (*model.UserEntity, error) {
 	user, err := s.userRepository.FindOneWithPredicates(ctx, s.userRepository.Query(ctx), user.EmailEQ(email))
 	if err != nil {
@@ -39,7 +39,7 @@ func main() {
 	}
 
 	templates := entgql.AllTemplates
-	templates = append(templates, db.PNNIDTemplate, db.EdgeTemplate)
+	templates = append(templates, db.PNNIDTemplate, db.EdgeTemplate, db.AuthorTemplate)
 
 	moduleName, err := utils.GetModuleName()
 	if err != nil {
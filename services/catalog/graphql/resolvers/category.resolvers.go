@@ -7,7 +7,6 @@ import (
 
 	"github.com/gianglt2198/federation-go/services/catalog/generated/ent"
 	"github.com/gianglt2198/federation-go/services/catalog/generated/graph/model"
-	"github.com/samber/lo"
 )
 
 // Category is the resolver for the category field.
@@ -46,18 +45,3 @@ func (r *mutationResolver) CatalogDeleteCategory(ctx context.Context, id string)
 	}
 	return true, nil
 }
-
-// Category is the resolver for the category field.
-func (r *categoryResolver) UserCreatedBy(ctx context.Context, obj *ent.Category) (*model.UserEntity, error) {
-	if obj.CreatedBy != nil {
-		return &model.UserEntity{ID: lo.FromPtr(obj.CreatedBy)}, nil
-	}
-	return nil, nil
-}
-
-func (r *categoryResolver) UserUpdatedBy(ctx context.Context, obj *ent.Category) (*model.UserEntity, error) {
-	if obj.UpdatedBy != nil {
-		return &model.UserEntity{ID: lo.FromPtr(obj.UpdatedBy)}, nil
-	}
-	return nil, nil
-}
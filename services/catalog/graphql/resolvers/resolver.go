@@ -40,8 +40,6 @@ func NewResolver(params ResolverParams) graphql.ExecutableSchema {
 type (
 	queryResolver    struct{ *Resolver }
 	mutationResolver struct{ *Resolver }
-
-	categoryResolver struct{ *Resolver }
 )
 
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
@@ -51,5 +49,3 @@ func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResol
 
 // Entity returns generated.EntityResolver implementation.
 // func (r *Resolver) Entity() generated.EntityResolver { return &entityResolver{r} }
-
-func (r *Resolver) Category() generated.CategoryResolver { return &categoryResolver{r} }
@@ -1,27 +1,75 @@
 package infra
 
 import (
+	stdsql "database/sql"
+
+	"entgo.io/ent/dialect"
 	"entgo.io/ent/dialect/sql"
 	"go.uber.org/fx"
 
 	"github.com/gianglt2198/federation-go/package/config"
 	"github.com/gianglt2198/federation-go/package/infras/monitoring/logging"
+	"github.com/gianglt2198/federation-go/package/infras/pubsub"
 	"github.com/gianglt2198/federation-go/package/modules/db"
+	"github.com/gianglt2198/federation-go/package/modules/db/hooks"
+	"github.com/gianglt2198/federation-go/package/modules/db/outbox"
 
 	"github.com/gianglt2198/federation-go/services/catalog/generated/ent"
 )
 
 var dbModule = fx.Module("db",
 	fx.Provide(NewDB),
+	outbox.Module,
 )
 
-func NewDB(cfg config.DatabaseConfig, logger *logging.Logger) *ent.Client {
+type DBResult struct {
+	fx.Out
+
+	Client *ent.Client
+	// DB is shared with the outbox relay
+	DB *stdsql.DB
+}
+
+func NewDB(
+	cfg config.DatabaseConfig,
+	appCfg config.AppConfig,
+	logger *logging.Logger,
+	publisher pubsub.Publisher,
+) DBResult {
+	driver := sql.OpenDB(cfg.Driver, db.NewDB(cfg, logger))
+
+	var entDriver dialect.Driver = driver
+	if cfg.Outbox.Enabled {
+		// the outbox hooks run the mutations and the writes of their events in a transaction of this driver
+		entDriver = outbox.NewDriver(driver)
+	}
+
 	opts := []ent.Option{
-		ent.Driver(sql.OpenDB(cfg.Driver, db.NewDB(cfg, logger))),
+		ent.Driver(entDriver),
 	}
 
 	if cfg.Debug {
 		opts = append(opts, ent.Debug())
 	}
-	return ent.NewClient(opts...)
+
+	client := ent.NewClient(opts...)
+
+	// the outbox publishes the product and category changes once committed, the hooks right after
+	// the mutation, even if its transaction rolls back
+	if cfg.Outbox.Enabled {
+		client.Use(
+			outbox.Hook(appCfg.Name),
+			outbox.FederationHook(logger, cfg.EntityEvents),
+		)
+	} else {
+		client.Use(
+			hooks.PublishEntityChangeHook(appCfg.Name, publisher, logger),
+			hooks.PublishFederationEventHook(publisher, logger, cfg.EntityEvents),
+		)
+	}
+
+	return DBResult{
+		Client: client,
+		DB:     driver.DB(),
+	}
 }
@@ -0,0 +1,13 @@
+-- Create "outbox_events" table
+CREATE TABLE "outbox_events" (
+  "id" character varying NOT NULL,
+  "topic" character varying NOT NULL,
+  "payload" bytea NOT NULL,
+  "created_at" timestamptz NOT NULL,
+  "attempts" bigint NOT NULL DEFAULT 0,
+  "last_error" text NULL,
+  "published_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+-- Create index "outbox_events_pending" to table: "outbox_events"
+CREATE INDEX "outbox_events_pending" ON "outbox_events" ("created_at") WHERE "published_at" IS NULL;
@@ -7,6 +7,7 @@ replace github.com/gianglt2198/federation-go/package => ../../package
 require (
 	github.com/99designs/gqlgen v0.17.89
 	github.com/gianglt2198/federation-go/package v0.0.0-00010101000000-000000000000
+	github.com/wundergraph/cosmo/composition-go v0.0.0-20241020204711-78f240a77c99
 	go.uber.org/fx v1.24.0
 )
 
@@ -54,7 +55,7 @@ require (
 	github.com/mattn/go-colorable v0.1.14 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-runewidth v0.0.16 // indirect
-	github.com/nats-io/nats.go v1.42.0 // indirect
+	github.com/nats-io/nats.go v1.43.0 // indirect
 	github.com/nats-io/nkeys v0.4.11 // indirect
 	github.com/nats-io/nuid v1.0.1 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
@@ -92,7 +93,6 @@ require (
 	github.com/vmihailenco/msgpack/v5 v5.4.1 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	github.com/wundergraph/astjson v0.0.0-20250106123708-be463c97e083 // indirect
-	github.com/wundergraph/cosmo/composition-go v0.0.0-20241020204711-78f240a77c99 // indirect
 	github.com/wundergraph/cosmo/router v0.0.0-20250718094304-9f75c0e48acd // indirect
 	github.com/wundergraph/graphql-go-tools/execution v1.4.0 // indirect
 	github.com/wundergraph/graphql-go-tools/v2 v2.0.0-rc.207 // indirect
@@ -116,7 +116,7 @@ require (
 	golang.org/x/sync v0.20.0 // indirect
 	golang.org/x/sys v0.42.0 // indirect
 	golang.org/x/text v0.34.0 // indirect
-	golang.org/x/time v0.9.0 // indirect
+	golang.org/x/time v0.12.0 // indirect
 	golang.org/x/tools v0.42.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250106144421-5f5ef82da422 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect